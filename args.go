@@ -0,0 +1,45 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// argsAttrs returns the args attribute of args, capped to maxArgsSize bytes when set: the
+// arguments are kept while their formatted size fits, the first one that does not fit is
+// cut, and the args_truncated and args_count attributes report the truncation.
+func (op *operation) argsAttrs(args any) []slog.Attr {
+	if op.maxArgsSize <= 0 {
+		return []slog.Attr{slog.Any("args", args)}
+	}
+	values, ok := args.([]any)
+	if !ok {
+		values = []any{args}
+	}
+	kept := make([]any, 0, len(values))
+	size := 0
+	for _, v := range values {
+		text := fmt.Sprint(v)
+		if size+len(text) <= op.maxArgsSize {
+			kept = append(kept, v)
+			size += len(text) + 1 // separating space
+			continue
+		}
+		if rest := op.maxArgsSize - size; rest > 0 {
+			kept = append(kept, truncate(text, rest)+truncationMarker)
+		}
+		var capped any = kept
+		if !ok && len(kept) > 0 {
+			capped = kept[0]
+		}
+		return []slog.Attr{
+			slog.Any("args", capped),
+			slog.Bool("args_truncated", true),
+			slog.Int("args_count", len(values)),
+		}
+	}
+	return []slog.Attr{slog.Any("args", args)}
+}
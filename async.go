@@ -0,0 +1,169 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// record is a log entry waiting in the asynchronous queue.
+type record struct {
+	ctx   context.Context
+	level slog.Level
+	msg   string
+	attrs []slog.Attr
+	flush chan struct{} // non-nil for flush markers, closed once every earlier record is written.
+}
+
+// asyncSink writes records to a logger from a background goroutine so that
+// slow slog handlers never block query execution. Records at warning level and above,
+// such as errors and slow statements, travel through a separate high-priority queue
+// that is always served first, so routine records can never crowd them out.
+type asyncSink struct {
+	logger   *slog.Logger
+	high     chan record   // records at slog.LevelWarn and above.
+	low      chan record   // routine records and flush markers.
+	timeout  time.Duration // maximum time spent draining routine records on Close, zero waits for all.
+	deadline time.Time     // deadline of the drain, set by Close.
+	closing  chan struct{} // closed when Close is called.
+	done     chan struct{} // closed when the background goroutine exits.
+	mu       sync.RWMutex  // guards closed against concurrent sends.
+	closed   bool
+	dropped  atomic.Uint64 // records discarded because the queue was full.
+}
+
+func newAsyncSink(logger *slog.Logger, size int, timeout time.Duration) *asyncSink {
+	s := &asyncSink{
+		logger:  logger,
+		high:    make(chan record, size),
+		low:     make(chan record, size),
+		timeout: timeout,
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *asyncSink) run() {
+	defer close(s.done)
+	for {
+		var r record
+		var ok bool
+		select {
+		case r, ok = <-s.high:
+		default:
+			select {
+			case r, ok = <-s.high:
+			case r, ok = <-s.low:
+			case <-s.closing:
+			}
+		}
+		if !ok {
+			s.drain()
+			return
+		}
+		if r.flush != nil {
+			s.drainHigh()
+		}
+		s.write(r)
+	}
+}
+
+func (s *asyncSink) write(r record) {
+	if r.flush != nil {
+		close(r.flush)
+		return
+	}
+	s.logger.LogAttrs(r.ctx, r.level, r.msg, r.attrs...)
+}
+
+// drainHigh writes the high-priority records currently queued.
+func (s *asyncSink) drainHigh() {
+	for {
+		select {
+		case r, ok := <-s.high:
+			if !ok {
+				return
+			}
+			s.write(r)
+		default:
+			return
+		}
+	}
+}
+
+// drain writes the records left in the queues on Close. High-priority records are always
+// written first; routine records are discarded once the shutdown deadline has passed.
+// The number of lost records is reported in a final warning.
+func (s *asyncSink) drain() {
+	for r := range s.high {
+		s.write(r)
+	}
+	var discarded int
+	for r := range s.low {
+		if r.flush == nil && s.timeout > 0 && time.Now().After(s.deadline) {
+			discarded++
+			continue
+		}
+		s.write(r)
+	}
+	if dropped := s.dropped.Load(); dropped > 0 || discarded > 0 {
+		s.logger.LogAttrs(context.Background(), slog.LevelWarn, "async log queue closed",
+			slog.Uint64("dropped", dropped), slog.Int("discarded", discarded))
+	}
+}
+
+// log enqueues a record without blocking. Records are dropped when the queue is full,
+// and written synchronously once the sink has been closed.
+func (s *asyncSink) log(ctx context.Context, level slog.Level, msg string, attrs []slog.Attr) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.logger.LogAttrs(ctx, level, msg, attrs...)
+		return
+	}
+	queue := s.low
+	if level >= slog.LevelWarn {
+		queue = s.high
+	}
+	select {
+	case queue <- record{ctx: ctx, level: level, msg: msg, attrs: attrs}:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Flush blocks until every record queued before the call has been written.
+func (s *asyncSink) Flush() {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return
+	}
+	flush := make(chan struct{})
+	s.low <- record{flush: flush}
+	s.mu.RUnlock()
+	<-flush
+}
+
+// Close stops accepting records and waits for the queue to drain.
+func (s *asyncSink) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.deadline = time.Now().Add(s.timeout)
+	s.closed = true
+	close(s.closing)
+	close(s.high)
+	close(s.low)
+	s.mu.Unlock()
+	<-s.done
+}
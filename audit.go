@@ -0,0 +1,169 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type (
+	// AuditEvent is an entry of the mutation audit trail written by WithAuditSink, one JSON
+	// object per line. Each entry carries the hash of the previous one, so removing, inserting
+	// or altering entries is detected by VerifyAuditChain.
+	AuditEvent struct {
+		Seq       uint64                 `json:"seq"`                  // Seq numbers the entries of a chain from 1.
+		Time      time.Time              `json:"time"`                 // Time is the completion time of the operation.
+		Event     string                 `json:"event"`                // Event is mutation, commit or rollback.
+		TxID      string                 `json:"tx_id,omitempty"`      // TxID is the transaction id, empty outside transactions.
+		RequestID string                 `json:"request_id,omitempty"` // RequestID is the request id of the context, if any.
+		Op        string                 `json:"op,omitempty"`         // Op is the statement class of mutations.
+		Tables    []string               `json:"tables,omitempty"`     // Tables are the tables touched by mutations.
+		Query     string                 `json:"query,omitempty"`      // Query is the statement of mutations, scrubbed when sensitive.
+		Diff      map[string]AuditChange `json:"diff,omitempty"`       // Diff holds the field changes supplied by WithMutationDiff.
+		Prev      string                 `json:"prev"`                 // Prev is the hash of the previous entry, empty for the first one.
+		Hash      string                 `json:"hash,omitempty"`       // Hash is the hex SHA-256 of the entry without its hash.
+	}
+	// AuditChange is the change of a field recorded by the audit trail.
+	AuditChange struct {
+		Before any `json:"before"`
+		After  any `json:"after"`
+	}
+)
+
+// auditHashField precedes the hash, which is always the last field of an entry.
+var auditHashField = []byte(`,"hash":"`)
+
+// auditChain appends hash-chained entries to an audit sink.
+type auditChain struct {
+	mu   sync.Mutex
+	w    io.Writer
+	seq  uint64
+	prev string
+}
+
+// append completes e with its sequence number and hashes, and writes it to the sink.
+func (c *auditChain) append(e AuditEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.Seq, e.Prev, e.Hash = c.seq+1, c.prev, ""
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(payload)
+	hash := hex.EncodeToString(sum[:])
+	line := make([]byte, 0, len(payload)+len(auditHashField)+len(hash)+3)
+	line = append(line, payload[:len(payload)-1]...)
+	line = append(append(append(line, auditHashField...), hash...), "\"}\n"...)
+	if _, err := c.w.Write(line); err != nil {
+		return err
+	}
+	c.seq, c.prev = e.Seq, hash
+	return nil
+}
+
+// VerifyAuditChain reads the audit trail written by WithAuditSink and returns an error
+// naming the first line that was altered, inserted or whose predecessor was removed.
+// Each driver starts a new chain, at sequence number 1, so a chain restarting after
+// the first line is a break unless allowRestarts is set, e.g. for the trail of several
+// driver instances appended to the same file. Restarts then also hide the removal of
+// the end of a chain, which must be checked by other means, such as the number of
+// driver instances that wrote the trail.
+//
+// - `r`: The reader of the audit trail.
+// - `allowRestarts`: Whether a new chain may start after the first line.
+func VerifyAuditChain(r io.Reader, allowRestarts bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	var (
+		prev string
+		seq  uint64
+	)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		i := bytes.LastIndex(line, auditHashField)
+		if i < 0 || !bytes.HasSuffix(line, []byte(`"}`)) {
+			return fmt.Errorf("entslog: audit line %d: missing hash", n)
+		}
+		hash := string(line[i+len(auditHashField) : len(line)-2])
+		payload := append(line[:i:i], '}')
+		sum := sha256.Sum256(payload)
+		if hex.EncodeToString(sum[:]) != hash {
+			return fmt.Errorf("entslog: audit line %d: hash mismatch", n)
+		}
+		var e AuditEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return fmt.Errorf("entslog: audit line %d: %w", n, err)
+		}
+		restart := e.Seq == 1 && e.Prev == "" && (n == 1 || allowRestarts)
+		if !restart && (e.Seq != seq+1 || e.Prev != prev) {
+			return fmt.Errorf("entslog: audit line %d: chain broken after sequence number %d", n, seq)
+		}
+		prev, seq = hash, e.Seq
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("entslog: audit: %w", err)
+	}
+	return nil
+}
+
+// auditMutation appends a successful INSERT, UPDATE or DELETE statement to the audit trail.
+func (op *operation) auditMutation(err error) {
+	if op.audit == nil || err != nil {
+		return
+	}
+	switch op.class {
+	case ClassInsert, ClassUpdate, ClassDelete:
+	default:
+		return
+	}
+	query := op.query
+	if op.sensitive {
+		query = scrubLiterals(query)
+	}
+	e := op.auditEvent("mutation")
+	e.Op, e.Tables, e.Query = op.class, op.tables, query
+	if len(op.diffs) > 0 {
+		e.Diff = make(map[string]AuditChange, len(op.diffs))
+		for _, d := range op.diffs {
+			e.Diff[d.Field] = AuditChange{Before: d.Before, After: d.After}
+		}
+	}
+	if op.audited != nil {
+		op.audited.Store(true)
+	}
+	op.writeAudit(e)
+}
+
+// auditTx appends the outcome of a transaction that recorded mutations to the audit trail.
+func (op *operation) auditTx(event string, err error) {
+	if op.audit == nil || err != nil || op.audited == nil || !op.audited.Load() {
+		return
+	}
+	op.writeAudit(op.auditEvent(event))
+}
+
+func (op *operation) auditEvent(event string) AuditEvent {
+	return AuditEvent{
+		Time:      op.start.Add(op.took),
+		Event:     event,
+		TxID:      op.txID,
+		RequestID: RequestIDFromContext(op.ctx),
+	}
+}
+
+func (op *operation) writeAudit(e AuditEvent) {
+	if err := op.audit.append(e); err != nil {
+		op.LogError(context.WithoutCancel(op.ctx), "audit", err)
+	}
+}
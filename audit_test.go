@@ -0,0 +1,65 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// auditTrail returns the audit trail of a driver issuing statements.
+func auditTrail(t *testing.T, statements ...string) []byte {
+	t.Helper()
+	var (
+		log   testLog
+		trail bytes.Buffer
+	)
+	drv := newTestDriver(&fakeDriver{clock: newTestClock()}, &log, WithAuditSink(&trail))
+	for _, query := range statements {
+		if err := drv.Exec(context.Background(), query, []any{1}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return trail.Bytes()
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	trail := auditTrail(t, "INSERT INTO t (a) VALUES (?)", "SELECT a FROM t", "UPDATE t SET a = ?", "DELETE FROM t WHERE a = ?")
+	lines := strings.SplitAfter(string(trail), "\n")
+	lines = lines[:len(lines)-1]
+	if len(lines) != 3 {
+		t.Fatalf("audit trail has %d entries, want 3:\n%s", len(lines), trail)
+	}
+	if err := VerifyAuditChain(bytes.NewReader(trail), false); err != nil {
+		t.Errorf("VerifyAuditChain = %v", err)
+	}
+	other := string(auditTrail(t, "INSERT INTO t (a) VALUES (?)"))
+	tests := []struct {
+		name          string
+		trail         string
+		allowRestarts bool
+		err           string
+	}{
+		{"removed", lines[0] + lines[2], false, "line 2: chain broken"},
+		{"reordered", lines[1] + lines[0] + lines[2], false, "line 1: chain broken"},
+		{"altered", strings.Replace(lines[0], "INSERT", "insert", 1), false, "line 1: hash mismatch"},
+		{"restart", lines[0] + lines[1] + other, false, "line 3: chain broken"},
+		{"truncated restart", lines[0] + other + lines[2], false, "line 2: chain broken"},
+		{"allowed restart", lines[0] + lines[1] + other, true, ""},
+		{"allowed restart then removed", lines[0] + other + lines[2], true, "line 3: chain broken"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyAuditChain(strings.NewReader(tt.trail), tt.allowRestarts)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("VerifyAuditChain = %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("VerifyAuditChain = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+)
+
+// BenignFunc reports whether err is part of the normal control flow, such as a missing row,
+// and logged at Debug level instead of the error level.
+type BenignFunc func(err error) bool
+
+// notFound holds the default benign error functions.
+var notFound = []BenignFunc{IsNotFound}
+
+// IsNotFound reports whether err is sql.ErrNoRows or wraps an ent NotFoundError. As the
+// NotFoundError type is generated in the ent package of each project, it is matched by name.
+func IsNotFound(err error) bool {
+	if errors.Is(err, sql.ErrNoRows) {
+		return true
+	}
+	for err != nil {
+		t := reflect.TypeOf(err)
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Name() == "NotFoundError" {
+			return true
+		}
+		err = errors.Unwrap(err)
+	}
+	return false
+}
+
+// isBenign reports whether one of the benign error functions matches err.
+func (h *Handler) isBenign(err error) bool {
+	for _, benign := range h.benign {
+		if benign(err) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"runtime"
+	"strings"
+)
+
+// callerFrameBatch is the number of stack frames read at once by callerFrame.
+const callerFrameBatch = 32
+
+// callerFrame returns the first stack frame outside this package, ent, database/sql
+// and ent generated packages (import paths ending in or containing an "ent" element).
+// The stack is read in batches until such a frame is found, however deep the ORM and
+// driver layers go.
+func callerFrame() (runtime.Frame, bool) {
+	var pcs [callerFrameBatch]uintptr
+	for skip := 3; ; skip += callerFrameBatch {
+		n := runtime.Callers(skip, pcs[:])
+		frames := runtime.CallersFrames(pcs[:n])
+		for {
+			frame, more := frames.Next()
+			if frame.Function != "" && !internalFrame(frame.Function) {
+				return frame, true
+			}
+			if !more {
+				break
+			}
+		}
+		if n < len(pcs) {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+// packageOf returns the import path of the package of a fully qualified function name.
+func packageOf(function string) string {
+	pkg := function
+	if i := strings.LastIndexByte(pkg, '/'); i >= 0 {
+		if j := strings.IndexByte(pkg[i:], '.'); j >= 0 {
+			pkg = pkg[:i+j]
+		}
+	} else if j := strings.IndexByte(pkg, '.'); j >= 0 {
+		pkg = pkg[:j]
+	}
+	return pkg
+}
+
+// internalFrame reports whether function belongs to the database access layers.
+func internalFrame(function string) bool {
+	pkg := packageOf(function)
+	switch {
+	case strings.HasPrefix(pkg, "github.com/origadmin/entslog"),
+		strings.HasPrefix(pkg, "entgo.io/"),
+		strings.HasPrefix(pkg, "database/sql"),
+		strings.HasPrefix(pkg, "runtime"),
+		pkg == "ent" || strings.HasSuffix(pkg, "/ent") || strings.Contains(pkg, "/ent/"):
+		return true
+	}
+	return false
+}
+
+// originHash returns a stable hash of a statement fingerprint and the package issuing it,
+// which unlike file paths and line numbers does not change between builds.
+func originHash(fingerprint string) string {
+	pkg := ""
+	if frame, ok := callerFrame(); ok {
+		pkg = packageOf(frame.Function)
+	}
+	return queryHash(fingerprint + "\x00" + pkg)
+}
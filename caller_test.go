@@ -0,0 +1,57 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// deepCallerFrame calls callerFrame under depth frames of this package, as the deep
+// stacks of ORM calls do.
+func deepCallerFrame(depth int) (runtime.Frame, bool) {
+	if depth == 0 {
+		return callerFrame()
+	}
+	frame, ok := deepCallerFrame(depth - 1)
+	runtime.KeepAlive(depth)
+	return frame, ok
+}
+
+func TestCallerFrame(t *testing.T) {
+	for _, depth := range []int{0, 10, callerFrameBatch, 3 * callerFrameBatch, 1000} {
+		frame, ok := deepCallerFrame(depth)
+		if !ok {
+			t.Errorf("depth %d: no caller frame", depth)
+			continue
+		}
+		// The test function is in this package, the first external frame is the testing package.
+		if !strings.HasPrefix(frame.Function, "testing.") {
+			t.Errorf("depth %d: caller frame %s, want the testing package", depth, frame.Function)
+		}
+	}
+}
+
+func TestInternalFrame(t *testing.T) {
+	tests := []struct {
+		function string
+		want     bool
+	}{
+		{"github.com/origadmin/entslog/v3.(*SlogDriver).Exec", true},
+		{"entgo.io/ent/dialect/sql.(*Driver).Exec", true},
+		{"database/sql.(*DB).QueryContext", true},
+		{"example.com/app/ent.(*UserQuery).All", true},
+		{"example.com/app/ent/user.ByID", true},
+		{"ent.(*Client).Close", true},
+		{"example.com/app/service.(*Users).List", false},
+		{"example.com/tenant.Load", false},
+		{"main.main", false},
+	}
+	for _, tt := range tests {
+		if got := internalFrame(tt.function); got != tt.want {
+			t.Errorf("internalFrame(%q) = %t, want %t", tt.function, got, tt.want)
+		}
+	}
+}
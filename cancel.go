@@ -0,0 +1,55 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// cancelWatch records when the context of an operation was canceled.
+type cancelWatch struct {
+	at   atomic.Int64 // unix nanoseconds of the cancellation, zero while the context is live.
+	stop func() bool  // unregisters the watch.
+}
+
+// watchCancel starts recording the cancellation time of ctx, it returns nil when
+// ctx can never be canceled.
+func watchCancel(ctx context.Context, now func() time.Time) *cancelWatch {
+	if ctx.Done() == nil {
+		return nil
+	}
+	w := new(cancelWatch)
+	w.stop = context.AfterFunc(ctx, func() {
+		w.at.Store(now().UnixNano())
+	})
+	return w
+}
+
+// checkCancellation warns when the operation completed more than the configured grace
+// period after its context was canceled, i.e. the driver or the database kept working
+// on a statement nobody waits for anymore.
+func (op *operation) checkCancellation(end time.Time) {
+	if op.cancel == nil {
+		return
+	}
+	op.cancel.stop()
+	at := op.cancel.at.Load()
+	if at == 0 {
+		return
+	}
+	overrun := end.Sub(time.Unix(0, at))
+	if overrun <= op.cancelGrace {
+		return
+	}
+	attrs := append(op.subjectAttrs(),
+		slog.String("operation", op.name),
+		slog.Duration("overrun", overrun),
+		slog.Duration("duration", op.took),
+		slog.Any("cause", context.Cause(op.ctx)),
+	)
+	op.logAt(op.ctx, slog.LevelWarn, "operation outlived context cancellation", attrs...)
+}
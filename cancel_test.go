@@ -0,0 +1,58 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCancellationPropagationCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		grace    time.Duration
+		took     time.Duration
+		canceled bool
+		warned   bool
+	}{
+		{"overrun", 100 * time.Millisecond, time.Second, true, true},
+		{"within grace", 100 * time.Millisecond, 50 * time.Millisecond, true, false},
+		{"default grace overrun", 0, 150 * time.Millisecond, true, true},
+		{"default grace", 0, 50 * time.Millisecond, true, false},
+		{"not canceled", 100 * time.Millisecond, time.Second, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log testLog
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			dri := newCancelingDriver(tt.took, cancel)
+			drv := New(dri, WithLogger(log.Logger()), WithClock(dri.Now), WithCancellationPropagationCheck(tt.grace))
+			if !tt.canceled {
+				drv = newTestDriver(dri.fakeDriver, &log, WithCancellationPropagationCheck(tt.grace))
+			}
+			if err := drv.Exec(ctx, "UPDATE t SET a = ?", []any{1}, nil); err != nil {
+				t.Fatal(err)
+			}
+			records := log.Records(t, "operation outlived context cancellation")
+			if !tt.warned {
+				if len(records) != 0 {
+					t.Errorf("got cancellation warnings %v", records)
+				}
+				return
+			}
+			if len(records) != 1 {
+				t.Fatalf("got %d cancellation warnings, want 1", len(records))
+			}
+			record := records[0]
+			if got := time.Duration(record["overrun"].(float64)); got != tt.took {
+				t.Errorf("overrun = %v, want %v", got, tt.took)
+			}
+			if record["level"] != "WARN" || record["operation"] != "Exec" || record["cause"] != context.Canceled.Error() {
+				t.Errorf("cancellation warning = %v", record)
+			}
+		})
+	}
+}
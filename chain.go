@@ -0,0 +1,153 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// chainKey is the key of the group carrying the chain of a record.
+const chainKey = "chain"
+
+// recordChain links the records of a driver instance by hashes.
+type recordChain struct {
+	id   string
+	mu   sync.Mutex
+	seq  uint64
+	prev string
+}
+
+func newRecordChain() *recordChain {
+	return &recordChain{id: uuid.Must(uuid.NewRandom()).String()}
+}
+
+// link returns attrs followed by the chain group of the next record, whose hash covers the
+// level, message and attributes of the record and the hash of the previous record.
+func (c *recordChain) link(ctx context.Context, level slog.Level, msg string, attrs []slog.Attr) []slog.Attr {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	chain := []any{slog.String("id", c.id), slog.Uint64("seq", c.seq+1), slog.String("prev", c.prev)}
+	// The zero time is omitted by the JSON handler, as the time of the record is set by the logger.
+	var buf bytes.Buffer
+	r := slog.NewRecord(time.Time{}, level, msg, 0)
+	r.AddAttrs(attrs...)
+	r.AddAttrs(slog.Group(chainKey, chain...))
+	if err := slog.NewJSONHandler(&buf, nil).Handle(ctx, r); err != nil {
+		return attrs
+	}
+	hash, err := recordHash(buf.Bytes())
+	if err != nil {
+		return attrs
+	}
+	c.seq, c.prev = c.seq+1, hash
+	return append(slices.Clip(attrs), slog.Group(chainKey, append(chain, slog.String("hash", hash))...))
+}
+
+// recordHash returns the hex SHA-256 of the canonical form of the JSON record line, with
+// its keys sorted at every level.
+func recordHash(line []byte) (string, error) {
+	m, err := decodeRecord(line)
+	if err != nil {
+		return "", err
+	}
+	canonical, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func decodeRecord(line []byte) (map[string]any, error) {
+	d := json.NewDecoder(bytes.NewReader(line))
+	d.UseNumber()
+	var m map[string]any
+	if err := d.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// VerifyRecordChain reads the records written by slog.JSONHandler for a driver configured
+// with WithRecordChain and returns an error naming the first chained record that was
+// altered, or whose predecessor was removed or inserted. The time and source keys are not
+// covered by the chain and ignored, as are the keys listed in ignore, such as the
+// attributes added to the logger with slog.Logger.With. Records without a chain, e.g. of
+// other loggers, are skipped, and the chains of several driver instances may be interleaved.
+func VerifyRecordChain(r io.Reader, ignore ...string) error {
+	type link struct {
+		line int
+		seq  uint64
+		prev string
+		hash string
+	}
+	chains := make(map[string][]link)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for n := 1; scanner.Scan(); n++ {
+		m, err := decodeRecord(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("entslog: record line %d: %w", n, err)
+		}
+		chain, ok := m[chainKey].(map[string]any)
+		if !ok {
+			continue
+		}
+		id, _ := chain["id"].(string)
+		prev, _ := chain["prev"].(string)
+		hash, _ := chain["hash"].(string)
+		number, _ := chain["seq"].(json.Number)
+		seq, err := number.Int64()
+		if err != nil || id == "" || hash == "" {
+			return fmt.Errorf("entslog: record line %d: malformed chain", n)
+		}
+		delete(chain, "hash")
+		delete(m, slog.TimeKey)
+		delete(m, slog.SourceKey)
+		for _, key := range ignore {
+			delete(m, key)
+		}
+		canonical, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("entslog: record line %d: %w", n, err)
+		}
+		if sum := sha256.Sum256(canonical); hex.EncodeToString(sum[:]) != hash {
+			return fmt.Errorf("entslog: record line %d: hash mismatch", n)
+		}
+		chains[id] = append(chains[id], link{line: n, seq: uint64(seq), prev: prev, hash: hash})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("entslog: records: %w", err)
+	}
+	for _, links := range chains {
+		// Concurrent and asynchronous records may be written out of order.
+		slices.SortFunc(links, func(a, b link) int { return cmp.Compare(a.seq, b.seq) })
+		var prev link
+		for i, l := range links {
+			if i == 0 && l.seq == 1 && l.prev == "" {
+				prev = l
+				continue
+			}
+			if i == 0 || l.seq != prev.seq+1 || l.prev != prev.hash {
+				return fmt.Errorf("entslog: record line %d: chain broken after sequence number %d", l.line, prev.seq)
+			}
+			prev = l
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// eventClock issues the process-wide ordering ids of WithEventClock.
+var eventClock struct {
+	base time.Time     // process start, carrying a monotonic clock reading.
+	seq  atomic.Uint64 // number of ids issued.
+}
+
+func init() {
+	eventClock.base = time.Now()
+}
+
+// nextEventID returns a new event id made of a coarse timestamp, in milliseconds since the
+// Unix epoch, and a sequence number, both zero-padded so that ids sort lexically. The
+// timestamp is derived from the monotonic clock, it never goes backwards when the wall
+// clock is corrected, and the sequence number totally orders the ids of the process.
+func nextEventID() string {
+	seq := eventClock.seq.Add(1)
+	now := eventClock.base.Add(time.Since(eventClock.base))
+	return fmt.Sprintf("%013d-%010d", now.UnixMilli(), seq)
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sqlComment builds the sqlcommenter comment for a statement executed under ctx,
+// or returns an empty string when there is nothing to attach.
+func (h *Handler) sqlComment(ctx context.Context) string {
+	tags := make(map[string]string, 2)
+	if h.application != "" {
+		tags["application"] = h.application
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		tags["traceparent"] = "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-" + sc.TraceFlags().String()
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("/*")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(url.QueryEscape(k))
+		b.WriteString("='")
+		b.WriteString(url.PathEscape(tags[k]))
+		b.WriteByte('\'')
+	}
+	b.WriteString("*/")
+	return b.String()
+}
+
+// withComment appends comment to query, keeping a trailing semicolon last. Queries that
+// already carry a comment are left untouched, as required by the sqlcommenter specification.
+// Comment markers within literals and quoted identifiers, such as 'a--b', are not comments.
+func withComment(query, comment string) string {
+	if comment == "" || hasComment(query) {
+		return query
+	}
+	trimmed := strings.TrimRight(query, " \t\r\n")
+	if strings.HasSuffix(trimmed, ";") {
+		return strings.TrimSuffix(trimmed, ";") + " " + comment + ";"
+	}
+	return trimmed + " " + comment
+}
+
+// hasComment reports whether query contains a block or line comment.
+func hasComment(query string) bool {
+	if !strings.Contains(query, "/*") && !strings.Contains(query, "--") {
+		return false
+	}
+	for _, t := range scanSQL(query) {
+		if t.kind == tokComment {
+			return true
+		}
+	}
+	return false
+}
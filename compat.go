@@ -0,0 +1,110 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	stdsql "database/sql"
+
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql"
+)
+
+// The methods ent probes for beyond dialect.Driver and dialect.Tx are bridged at run time:
+// ExecContext, QueryContext and BeginTx forward to the underlying driver when it provides
+// them. Otherwise ExecContext falls back to Exec, and QueryContext and BeginTx fail with
+// ErrUnsupported unless WithDeprecatedDriverMethodBridging finds a fallback. The assertions
+// below break the build, rather than the behavior, should a future ent release change any
+// of these method sets.
+var (
+	_ dialect.Driver = (*SlogDriver)(nil)
+	_ dialect.Tx     = (*SlogTx)(nil)
+
+	_ interface {
+		ExecContext(context.Context, string, ...any) (sql.Result, error)
+		QueryContext(context.Context, string, ...any) (*stdsql.Rows, error)
+		BeginTx(context.Context, *sql.TxOptions) (dialect.Tx, error)
+	} = (*SlogDriver)(nil)
+	_ interface {
+		ExecContext(context.Context, string, ...any) (sql.Result, error)
+		QueryContext(context.Context, string, ...any) (*stdsql.Rows, error)
+	} = (*SlogTx)(nil)
+)
+
+type (
+	// queryContexter is implemented by the drivers and transactions supporting QueryContext.
+	queryContexter interface {
+		QueryContext(context.Context, string, ...any) (*stdsql.Rows, error)
+	}
+	// beginTxer is implemented by the drivers supporting BeginTx.
+	beginTxer interface {
+		BeginTx(context.Context, *sql.TxOptions) (dialect.Tx, error)
+	}
+	// dbProvider is implemented by the drivers exposing their database, such as *sql.Driver.
+	dbProvider interface {
+		DB() *stdsql.DB
+	}
+	// beginTxFunc is a function implementing beginTxer.
+	beginTxFunc func(context.Context, *sql.TxOptions) (dialect.Tx, error)
+)
+
+// BeginTx implements beginTxer.
+func (f beginTxFunc) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+	return f(ctx, opts)
+}
+
+// databaseOf returns the database of the first of drivers exposing one, nil if none does.
+func databaseOf(drivers ...dialect.Driver) *stdsql.DB {
+	for _, drv := range drivers {
+		if p, ok := drv.(dbProvider); ok {
+			return p.DB()
+		}
+	}
+	return nil
+}
+
+// bridgeQueryContext returns the database of drivers running QueryContext, or false when
+// none exposes its database.
+func bridgeQueryContext(drivers ...dialect.Driver) (queryContexter, bool) {
+	if db := databaseOf(drivers...); db != nil {
+		return db, true
+	}
+	return nil, false
+}
+
+// bridgeBeginTx returns a BeginTx running the Tx method of dri without options, and
+// beginning the transaction on the database of dri or exposed otherwise.
+func bridgeBeginTx(dri, exposed dialect.Driver) beginTxer {
+	return beginTxFunc(func(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
+		if opts == nil || *opts == (sql.TxOptions{}) {
+			return dri.Tx(ctx)
+		}
+		db := databaseOf(dri, exposed)
+		if db == nil {
+			return nil, unsupported("Driver.BeginTx")
+		}
+		tx, err := db.BeginTx(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &sql.Tx{Conn: sql.Conn{ExecQuerier: tx}, Tx: tx}, nil
+	})
+}
+
+// bridgeTxQueryContext returns the first transaction of the Unwrap chain of tx supporting
+// QueryContext, or false when none does.
+func bridgeTxQueryContext(tx dialect.Tx) (queryContexter, bool) {
+	for {
+		u, ok := tx.(interface{ Unwrap() dialect.Tx })
+		if !ok {
+			return nil, false
+		}
+		if tx = u.Unwrap(); tx == nil {
+			return nil, false
+		}
+		if qc, ok := tx.(queryContexter); ok {
+			return qc, true
+		}
+	}
+}
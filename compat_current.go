@@ -0,0 +1,10 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+//go:build !entslog_legacy_ent
+
+// Package entslog for entgo.io/ent
+package entslog
+
+// bridgeByDefault disables WithDeprecatedDriverMethodBridging by default, the drivers of
+// current ent releases implement the context methods.
+const bridgeByDefault = false
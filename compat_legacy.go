@@ -0,0 +1,10 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+//go:build entslog_legacy_ent
+
+// Package entslog for entgo.io/ent
+package entslog
+
+// bridgeByDefault enables WithDeprecatedDriverMethodBridging by default. Builds against ent
+// releases whose drivers predate the context methods set the entslog_legacy_ent tag.
+const bridgeByDefault = true
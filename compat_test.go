@@ -0,0 +1,216 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	stdsql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql"
+)
+
+// The drivers below emulate the surfaces of the ent releases entslog supports: legacyDriver
+// those predating the context methods, which only expose their database, and *sql.Driver
+// the current one.
+
+// legacyDriver is a driver of the ent releases predating ExecContext, QueryContext and BeginTx.
+type legacyDriver struct {
+	*fakeDriver
+	db *stdsql.DB
+}
+
+func (d *legacyDriver) DB() *stdsql.DB { return d.db }
+
+// legacyTx is a transaction wrapper forwarding neither ExecContext nor QueryContext.
+type legacyTx struct {
+	dialect.Tx
+}
+
+func (t legacyTx) Unwrap() dialect.Tx { return t.Tx }
+
+// legacyTxDriver is a driver returning its transactions wrapped in legacyTx.
+type legacyTxDriver struct {
+	*sql.Driver
+}
+
+func (d legacyTxDriver) Tx(ctx context.Context) (dialect.Tx, error) {
+	tx, err := d.Driver.Tx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return legacyTx{tx}, nil
+}
+
+func TestDeprecatedDriverMethodBridging(t *testing.T) {
+	ctx := context.Background()
+	opts := &sql.TxOptions{ReadOnly: true}
+	for _, bridge := range []bool{false, true} {
+		var log testLog
+		db := stdsql.OpenDB(testConnector{})
+		dri := &legacyDriver{fakeDriver: &fakeDriver{clock: newTestClock()}, db: db}
+		drv := New(dri, WithLogger(log.Logger()), WithDeprecatedDriverMethodBridging(bridge)).(*SlogDriver)
+
+		rows, err := drv.QueryContext(ctx, "SELECT 1")
+		if !bridge {
+			if !errors.Is(err, ErrUnsupported) {
+				t.Errorf("QueryContext without bridging: err = %v, want ErrUnsupported", err)
+			}
+		} else if err != nil {
+			t.Errorf("QueryContext: %v", err)
+		} else {
+			if !rows.Next() {
+				t.Error("QueryContext returned no rows")
+			}
+			rows.Close()
+		}
+
+		tx, err := drv.BeginTx(ctx, nil)
+		if !bridge {
+			if !errors.Is(err, ErrUnsupported) {
+				t.Errorf("BeginTx without bridging: err = %v, want ErrUnsupported", err)
+			}
+		} else if err != nil {
+			t.Errorf("BeginTx without options: %v", err)
+		} else if _, ok := tx.(*SlogTx).tx.(fakeTx); !ok {
+			t.Errorf("BeginTx without options began %T, want the transaction of Tx", tx.(*SlogTx).tx)
+		}
+
+		tx, err = drv.BeginTx(ctx, opts)
+		if !bridge {
+			if !errors.Is(err, ErrUnsupported) {
+				t.Errorf("BeginTx without bridging: err = %v, want ErrUnsupported", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("BeginTx with options: %v", err)
+		}
+		if _, err := tx.(*SlogTx).ExecContext(ctx, "UPDATE t SET a = 1"); err != nil {
+			t.Errorf("ExecContext: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Errorf("Commit: %v", err)
+		}
+		if records := log.Records(t, "BeginTx started"); len(records) != 2 || records[1]["read_only"] != true {
+			t.Errorf("BeginTx records = %v", records)
+		}
+	}
+}
+
+func TestDeprecatedDriverMethodBridgingNoDatabase(t *testing.T) {
+	var log testLog
+	drv := newTestDriver(&fakeDriver{clock: newTestClock()}, &log, WithDeprecatedDriverMethodBridging(true)).(*SlogDriver)
+	if _, err := drv.QueryContext(context.Background(), "SELECT 1"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("QueryContext: err = %v, want ErrUnsupported", err)
+	}
+	if _, err := drv.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("BeginTx: err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestDeprecatedDriverMethodBridgingTx(t *testing.T) {
+	ctx := context.Background()
+	for _, bridge := range []bool{false, true} {
+		var log testLog
+		dri := legacyTxDriver{sql.OpenDB(dialect.SQLite, stdsql.OpenDB(testConnector{}))}
+		drv := New(dri, WithLogger(log.Logger()), WithDeprecatedDriverMethodBridging(bridge))
+		tx, err := drv.Tx(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := tx.(*SlogTx).QueryContext(ctx, "SELECT 1")
+		switch {
+		case !bridge && !errors.Is(err, ErrUnsupported):
+			t.Errorf("QueryContext without bridging: err = %v, want ErrUnsupported", err)
+		case bridge && err != nil:
+			t.Errorf("QueryContext: %v", err)
+		case bridge:
+			rows.Close()
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCurrentDriverMethods(t *testing.T) {
+	ctx := context.Background()
+	// The methods of the current driver are used whether or not they are bridged.
+	for _, bridge := range []bool{false, true} {
+		var log testLog
+		dri := sql.OpenDB(dialect.SQLite, stdsql.OpenDB(testConnector{}))
+		drv := New(dri, WithLogger(log.Logger()), WithDeprecatedDriverMethodBridging(bridge)).(*SlogDriver)
+		rows, err := drv.QueryContext(ctx, "SELECT 1")
+		if err != nil {
+			t.Fatalf("QueryContext: %v", err)
+		}
+		rows.Close()
+		tx, err := drv.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			t.Fatalf("BeginTx: %v", err)
+		}
+		rows, err = tx.(*SlogTx).QueryContext(ctx, "SELECT 1")
+		if err != nil {
+			t.Fatalf("Tx.QueryContext: %v", err)
+		}
+		rows.Close()
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeprecatedDriverMethodBridgingDefault(t *testing.T) {
+	if got := NewOption().bridgeMethods; got != bridgeByDefault {
+		t.Errorf("bridging enabled by default = %t, want %t", got, bridgeByDefault)
+	}
+}
+
+// testConnector opens connections to a database answering every query with one row.
+type testConnector struct{}
+
+func (testConnector) Connect(context.Context) (driver.Conn, error) { return testConn{}, nil }
+func (c testConnector) Driver() driver.Driver                      { return c }
+func (testConnector) Open(string) (driver.Conn, error)             { return testConn{}, nil }
+
+type testConn struct{}
+
+func (testConn) Prepare(string) (driver.Stmt, error) { return testStmt{}, nil }
+func (testConn) Close() error                        { return nil }
+func (testConn) Begin() (driver.Tx, error)           { return testConn{}, nil }
+func (testConn) Commit() error                       { return nil }
+func (testConn) Rollback() error                     { return nil }
+
+func (testConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return testConn{}, nil
+}
+
+type testStmt struct{}
+
+func (testStmt) Close() error                               { return nil }
+func (testStmt) NumInput() int                              { return -1 }
+func (testStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (testStmt) Query([]driver.Value) (driver.Rows, error)  { return &testRows{}, nil }
+
+// testRows is a result set of a single row holding 1.
+type testRows struct {
+	done bool
+}
+
+func (*testRows) Columns() []string { return []string{"1"} }
+func (*testRows) Close() error      { return nil }
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
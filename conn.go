@@ -0,0 +1,61 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// connProbeKey is the context key under which an operation's connProbe is stored.
+type connProbeKey struct{}
+
+// connProbe records whether a fresh connection was dialed while an operation was running.
+type connProbe struct {
+	dials atomic.Int64 // number of connections dialed.
+	dial  atomic.Int64 // total dial latency in nanoseconds.
+}
+
+func (p *connProbe) attrs() []slog.Attr {
+	if p.dials.Load() == 0 {
+		return []slog.Attr{slog.String("conn", "pool")}
+	}
+	return []slog.Attr{slog.String("conn", "dial"), slog.Duration("dial_duration", time.Duration(p.dial.Load()))}
+}
+
+// Connector wraps a driver.Connector so that connections dialed on behalf of a logged
+// operation are reported when WithConnSource is enabled. Use it when opening the database:
+//
+//	db := sql.OpenDB(entslog.Connector(connector))
+//	drv := entslog.New(entsql.OpenDB(dialect.Postgres, db), entslog.WithConnSource())
+func Connector(c driver.Connector) driver.Connector {
+	return &connector{Connector: c}
+}
+
+type connector struct {
+	driver.Connector
+}
+
+// Connect dials through the wrapped connector and reports the dial to the operation in ctx.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	start := time.Now()
+	conn, err := c.Connector.Connect(ctx)
+	if p, ok := ctx.Value(connProbeKey{}).(*connProbe); ok && err == nil {
+		p.dials.Add(1)
+		p.dial.Add(int64(time.Since(start)))
+	}
+	return conn, err
+}
+
+// Close closes the wrapped connector if it implements io.Closer.
+func (c *connector) Close() error {
+	if closer, ok := c.Connector.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+)
+
+// contextAudit remembers the call sites already reported by the context audit.
+type contextAudit struct {
+	seen sync.Map // call site -> struct{}
+}
+
+// auditContext warns, once per call site, when an operation runs under context.Background
+// or context.TODO, i.e. a context without deadline, cancellation or request values.
+func (h *Handler) auditContext(ctx context.Context, name string) {
+	if h.ctxAudit == nil || (ctx != context.Background() && ctx != context.TODO()) {
+		return
+	}
+	frame, ok := callerFrame()
+	if !ok {
+		return
+	}
+	site := frame.File + ":" + strconv.Itoa(frame.Line)
+	if _, loaded := h.ctxAudit.seen.LoadOrStore(site, struct{}{}); loaded {
+		return
+	}
+	h.logAt(ctx, slog.LevelWarn, "operation without request context",
+		slog.String("operation", name),
+		slog.String("caller", site),
+		slog.String("function", frame.Function),
+	)
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+)
+
+// levelKey is the context key under which ContextWithLevel stores the default level.
+type levelKey struct{}
+
+// ContextWithLevel returns a copy of ctx under which, and under contexts derived from it,
+// the records of the operations are logged at level by default, overriding WithDefaultLevel,
+// WithLevelFor and WithMigrationLevel. With a driver logging at Debug to a logger enabled
+// at Info, a request carrying a debug header can be given verbose SQL logging with
+// slog.LevelInfo while the other requests stay quiet. Errors, slow statements and the
+// levels computed by WithLevelFunc are not affected.
+func ContextWithLevel(ctx context.Context, level slog.Leveler) context.Context {
+	return context.WithValue(ctx, levelKey{}, level)
+}
+
+// levelFromContext returns the default level stored by ContextWithLevel, if any.
+func levelFromContext(ctx context.Context) (slog.Leveler, bool) {
+	level, ok := ctx.Value(levelKey{}).(slog.Leveler)
+	return level, ok && level != nil
+}
+
+// silenceKey is the context key under which Silence marks a context.
+type silenceKey struct{}
+
+// Silence returns a copy of ctx under which, and under contexts derived from it, the
+// records of the operations are suppressed, e.g. for bulk imports and migrations whose
+// statements would flood the logs. Errors and warnings, such as slow statements, are
+// still logged.
+func Silence(ctx context.Context) context.Context {
+	return context.WithValue(ctx, silenceKey{}, true)
+}
+
+// silenced reports whether ctx was marked by Silence.
+func silenced(ctx context.Context) bool {
+	ok, _ := ctx.Value(silenceKey{}).(bool)
+	return ok
+}
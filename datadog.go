@@ -0,0 +1,37 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"strconv"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceIDsFunc extracts the trace and span ids carried by a context, ok is false when there are none.
+type TraceIDsFunc func(ctx context.Context) (traceID, spanID string, ok bool)
+
+// DatadogIDsFromOTel is a TraceIDsFunc converting the OpenTelemetry span of the context
+// to Datadog ids: the decimal value of the lower 64 bits of the trace id and of the span id.
+func DatadogIDsFromOTel(ctx context.Context) (traceID, spanID string, ok bool) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return "", "", false
+	}
+	tid, sid := sc.TraceID(), sc.SpanID()
+	return strconv.FormatUint(binary.BigEndian.Uint64(tid[8:]), 10),
+		strconv.FormatUint(binary.BigEndian.Uint64(sid[:]), 10), true
+}
+
+// datadogAttrs returns the dd.trace_id and dd.span_id attributes of ctx.
+func (h *Handler) datadogAttrs(ctx context.Context) []slog.Attr {
+	traceID, spanID, ok := h.datadogIDs(ctx)
+	if !ok {
+		return nil
+	}
+	return []slog.Attr{slog.String("dd.trace_id", traceID), slog.String("dd.span_id", spanID)}
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDatadogCorrelation(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0, 0, 0, 0, 0, 0, 0, 0xff, 0, 0, 0, 0, 0, 0, 0x01, 0x02},
+		SpanID:  trace.SpanID{0, 0, 0, 0, 0, 0, 0, 0x2a},
+	})
+	spanCtx := trace.ContextWithSpanContext(context.Background(), sc)
+	custom := func(ctx context.Context) (string, string, bool) { return "7", "8", true }
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		extract TraceIDsFunc
+		trace   any
+		span    any
+	}{
+		// The upper 64 bits of the trace id are dropped.
+		{"otel", spanCtx, nil, "258", "42"},
+		{"without span", context.Background(), nil, nil, nil},
+		{"custom", context.Background(), custom, "7", "8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log testLog
+			drv := newTestDriver(&fakeDriver{clock: newTestClock()}, &log, WithDatadogCorrelation(tt.extract))
+			if err := drv.Exec(tt.ctx, "UPDATE t SET a = ?", []any{1}, nil); err != nil {
+				t.Fatal(err)
+			}
+			records := log.Records(t, "Exec")
+			if len(records) != 1 {
+				t.Fatalf("got %d records, want 1", len(records))
+			}
+			if got := records[0]["dd.trace_id"]; got != tt.trace {
+				t.Errorf("dd.trace_id = %v, want %v", got, tt.trace)
+			}
+			if got := records[0]["dd.span_id"]; got != tt.span {
+				t.Errorf("dd.span_id = %v, want %v", got, tt.span)
+			}
+		})
+	}
+}
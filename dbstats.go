@@ -0,0 +1,38 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"entgo.io/ent/dialect"
+)
+
+// dbOf returns the *sql.DB of drv when it exposes one, as entsql.Driver does.
+func dbOf(drv dialect.Driver) (*sql.DB, bool) {
+	d, ok := drv.(interface{ DB() *sql.DB })
+	if !ok {
+		return nil, false
+	}
+	db := d.DB()
+	return db, db != nil
+}
+
+// logDBStats logs the connection pool statistics of db.
+func (h *Handler) logDBStats(db *sql.DB) {
+	stats := db.Stats()
+	h.Log(context.Background(), "DB stats",
+		slog.Int("max_open_connections", stats.MaxOpenConnections),
+		slog.Int("open_connections", stats.OpenConnections),
+		slog.Int("in_use", stats.InUse),
+		slog.Int("idle", stats.Idle),
+		slog.Int64("wait_count", stats.WaitCount),
+		slog.Duration("wait_duration", stats.WaitDuration),
+		slog.Int64("max_idle_closed", stats.MaxIdleClosed),
+		slog.Int64("max_idle_time_closed", stats.MaxIdleTimeClosed),
+		slog.Int64("max_lifetime_closed", stats.MaxLifetimeClosed),
+	)
+}
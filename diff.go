@@ -0,0 +1,65 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+)
+
+type (
+	// FieldDiff describes the change of a single field by an UPDATE statement.
+	FieldDiff struct {
+		Field  string // Field is the name of the changed field or column.
+		Before any    // Before is the value prior to the update.
+		After  any    // After is the value written by the update.
+	}
+	// MutationDiffFunc supplies the field diffs of an UPDATE statement executed in a transaction.
+	MutationDiffFunc func(ctx context.Context, query string, args any) []FieldDiff
+)
+
+// mutationDiffKey is the context key under which ContextWithMutationDiff stores diffs.
+type mutationDiffKey struct{}
+
+// ContextWithMutationDiff returns a copy of ctx carrying diffs for the UPDATE statements
+// executed under it. It is meant to be called from an ent hook that knows the old and new
+// field values, before passing the context on to the next mutator:
+//
+//	func(next ent.Mutator) ent.Mutator {
+//		return ent.MutateFunc(func(ctx context.Context, m ent.Mutation) (ent.Value, error) {
+//			return next.Mutate(entslog.ContextWithMutationDiff(ctx, diffs(ctx, m)...), m)
+//		})
+//	}
+func ContextWithMutationDiff(ctx context.Context, diffs ...FieldDiff) context.Context {
+	return context.WithValue(ctx, mutationDiffKey{}, diffs)
+}
+
+// MutationDiffFromContext is a MutationDiffFunc returning the diffs stored by ContextWithMutationDiff.
+func MutationDiffFromContext(ctx context.Context, _ string, _ any) []FieldDiff {
+	diffs, _ := ctx.Value(mutationDiffKey{}).([]FieldDiff)
+	return diffs
+}
+
+// diffAttr returns the diff attribute of an UPDATE statement in a transaction, if any.
+func (op *operation) diffAttr(args any, redact bool) (slog.Attr, bool) {
+	if op.mutationDiff == nil || op.txID == "" || op.class != ClassUpdate {
+		return slog.Attr{}, false
+	}
+	diffs := op.mutationDiff(op.ctx, op.query, args)
+	if len(diffs) == 0 {
+		return slog.Attr{}, false
+	}
+	fields := make([]any, 0, len(diffs))
+	op.diffs = make([]FieldDiff, 0, len(diffs))
+	for _, d := range diffs {
+		if redact || op.isSensitiveColumn(d.Field) {
+			d.Before, d.After = redactedValue, redactedValue
+		} else if op.argSalt != nil {
+			d.Before, d.After = hashArg(d.Before, op.argSalt), hashArg(d.After, op.argSalt)
+		}
+		op.diffs = append(op.diffs, d)
+		fields = append(fields, slog.Group(d.Field, slog.Any("before", d.Before), slog.Any("after", d.After)))
+	}
+	return slog.Group("diff", fields...), true
+}
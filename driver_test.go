@@ -0,0 +1,112 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"entgo.io/ent/dialect"
+)
+
+// testClock is a clock the fake driver moves forward by the duration of each statement.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// fakeDriver is a dialect.Driver whose statements take the duration in took, as
+// measured by clock, and return err.
+type fakeDriver struct {
+	clock *testClock
+	took  time.Duration
+	err   error
+}
+
+func (d *fakeDriver) Exec(context.Context, string, any, any) error {
+	d.clock.Advance(d.took)
+	return d.err
+}
+
+func (d *fakeDriver) Query(context.Context, string, any, any) error {
+	d.clock.Advance(d.took)
+	return d.err
+}
+
+func (d *fakeDriver) Tx(context.Context) (dialect.Tx, error) {
+	return fakeTx{d}, d.err
+}
+
+func (d *fakeDriver) Close() error    { return nil }
+func (d *fakeDriver) Dialect() string { return dialect.SQLite }
+
+// fakeTx is a transaction running its statements on the fake driver.
+type fakeTx struct {
+	*fakeDriver
+}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+// testLog collects the JSON records of a logger.
+type testLog struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *testLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+func (l *testLog) Logger() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(l, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+// Records returns the decoded records whose message is msg.
+func (l *testLog) Records(t *testing.T, msg string) []map[string]any {
+	t.Helper()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var records []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(l.buf.Bytes()))
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("decode record %q: %v", scanner.Text(), err)
+		}
+		if record[slog.MessageKey] == msg {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// newTestDriver returns a driver logging the fake driver to log, with the clock of the fake driver.
+func newTestDriver(fake *fakeDriver, log *testLog, ss ...Setting) dialect.Driver {
+	return New(fake, append([]Setting{WithLogger(log.Logger()), WithClock(fake.clock.Now)}, ss...)...)
+}
@@ -5,8 +5,13 @@ package entslog
 
 import (
 	"context"
+	stdsql "database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"entgo.io/ent/dialect"
 	"entgo.io/ent/dialect/sql"
@@ -17,88 +22,346 @@ import (
 type SlogDriver struct {
 	Handler                // log function. defaults to slog.Default()
 	dri     dialect.Driver // underlying init.
+	cancels []func()       // stop the background reporters.
+	exposed dialect.Driver // innermost driver whose optional interfaces are used when dri lacks them, nil unless created by Wrap.
+
+	closeOnce sync.Once
+	mu        sync.Mutex              // serializes Reconfigure.
+	opt       Option                  // options of the current handler.
+	live      atomic.Pointer[Handler] // current handler, replaced by Reconfigure.
 }
 
+// Close stops background reporters, drains the asynchronous log queue, if any,
+// and closes the underlying init.
 func (d *SlogDriver) Close() error {
+	d.closeOnce.Do(func() {
+		for _, cancel := range d.cancels {
+			cancel()
+		}
+	})
+	if d.sink != nil {
+		d.sink.Close()
+	}
 	return d.dri.Close()
 }
 
+// Flush blocks until all queued records have been written when asynchronous logging is enabled.
+func (d *SlogDriver) Flush() {
+	if d.sink != nil {
+		d.sink.Flush()
+	}
+}
+
+// Stats returns a snapshot of the operations performed by the driver, including
+// those of its transactions, since it was created.
+func (d *SlogDriver) Stats() Stats {
+	return d.stats.snapshot()
+}
+
+// History returns the statements kept by WithQueryHistory, oldest first, or nil when the
+// query history is disabled.
+func (d *SlogDriver) History() []HistoryEntry {
+	if h := d.handler(); h.history != nil {
+		return h.history.snapshot()
+	}
+	return nil
+}
+
 func (d *SlogDriver) Dialect() string {
 	return d.dri.Dialect()
 }
 
+// ErrUnsupported is wrapped by the errors of the methods of SlogDriver and SlogTx whose
+// optional interface the underlying driver or transaction does not implement, and has no
+// fallback. It matches errors.ErrUnsupported.
+var ErrUnsupported = fmt.Errorf("entslog: %w", errors.ErrUnsupported)
+
+// unsupported returns the error of the unsupported method.
+func unsupported(method string) error {
+	return fmt.Errorf("%s is not supported: %w", method, ErrUnsupported)
+}
+
+// execContexter is implemented by the drivers and transactions supporting ExecContext.
+type execContexter interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}
+
+// execContext calls the ExecContext method of drv or, failing that, its Exec method,
+// scanning the result into a sql.Result as the drivers of entgo.io/ent/dialect/sql do.
+func execContext(ctx context.Context, drv dialect.ExecQuerier, query string, args []any) (sql.Result, error) {
+	if drv, ok := drv.(execContexter); ok {
+		return drv.ExecContext(ctx, query, args...)
+	}
+	var result sql.Result
+	if err := drv.Exec(ctx, query, args, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Unwrap returns the underlying driver. Wrappers of dialect.Driver are expected to expose
+// the driver they wrap through an `Unwrap() dialect.Driver` method, so the stack can be
+// walked down, e.g. with InnermostDriver, to the driver whose concrete type is asserted.
+// Operations run directly on the returned driver are not logged.
+func (d *SlogDriver) Unwrap() dialect.Driver {
+	return d.dri
+}
+
 // New gets a init and an optional logging function, and returns
 // a new slog-init that prints all outgoing operations.
 func New(dri dialect.Driver, ss ...Setting) dialect.Driver {
-	opt := settings.Apply(&defaultOption, ss)
-	handle := makeHandle(opt)
-	return &SlogDriver{dri: dri, Handler: handle.with(slog.String("database", "driver"))}
+	opt := defaultOption
+	return newDriver(dri, settings.Apply(&opt, ss))
+}
+
+// NewFromDB returns a driver logging the operations of db, a database opened for the
+// dialect name, e.g. dialect.Postgres, without importing entgo.io/ent/dialect/sql.
+func NewFromDB(dialectName string, db *stdsql.DB, ss ...Setting) dialect.Driver {
+	return New(sql.OpenDB(dialectName, db), ss...)
+}
+
+// newDriver returns a driver logging the operations of dri with the options opt.
+func newDriver(dri dialect.Driver, opt *Option) *SlogDriver {
+	handle := makeHandle(dri.Dialect(), opt)
+	d := &SlogDriver{dri: dri, Handler: handle.with(slog.String("database", "driver")), opt: *opt}
+	if opt.recordChain {
+		d.chain = newRecordChain()
+	}
+	if opt.explainInterval > 0 {
+		d.explain = &explainer{dri: dri, interval: opt.explainInterval}
+	}
+	if opt.analyzeAfter > 0 {
+		if opt.development {
+			d.analyze = &explainer{dri: dri, interval: analyzeInterval, analyze: true, threshold: opt.analyzeAfter}
+		} else {
+			d.logAt(context.Background(), slog.LevelWarn, "explain analyze is only available in the development preset")
+		}
+	}
+	d.live.Store(&d.Handler)
+	d.logAt(context.Background(), slog.LevelDebug, "driver stack", slog.Any("stack", DescribeStack(d)))
+	if db, ok := dbOf(dri); ok && opt.dbStatsInterval > 0 {
+		d.every(opt.scheduler, opt.dbStatsInterval, func() { d.handler().logDBStats(db) })
+	}
+	if opt.statsStore != nil {
+		d.loadStats(opt.statsStore)
+		if opt.statsInterval > 0 {
+			d.every(opt.scheduler, opt.statsInterval, func() { d.handler().saveStats(opt.statsStore) })
+		}
+		d.cancels = append(d.cancels, func() { d.handler().saveStats(opt.statsStore) })
+	}
+	if d.report != nil {
+		d.every(opt.scheduler, opt.reportInterval, func() { d.handler().writeReport(opt.reportWriter) })
+		d.cancels = append(d.cancels, func() { d.handler().writeReport(opt.reportWriter) })
+	}
+	return d
+}
+
+// handler returns the current handler of the driver.
+func (d *SlogDriver) handler() *Handler {
+	return d.live.Load()
+}
+
+// Log logs with the current handler of the driver, see Handler.Log.
+func (d *SlogDriver) Log(ctx context.Context, msg string, attrs ...slog.Attr) {
+	d.handler().Log(ctx, msg, attrs...)
+}
+
+// LogError logs with the current handler of the driver, see Handler.LogError.
+func (d *SlogDriver) LogError(ctx context.Context, msg string, err error, attrs ...slog.Attr) error {
+	return d.handler().LogError(ctx, msg, err, attrs...)
+}
+
+// Filter filters with the current handler of the driver, see Handler.Filter.
+func (d *SlogDriver) Filter(ctx context.Context, attrs ...slog.Attr) []slog.Attr {
+	return d.handler().Filter(ctx, attrs...)
+}
+
+// WithTrace returns a trace id from the current handler of the driver, see Handler.WithTrace.
+func (d *SlogDriver) WithTrace(ctx context.Context) string {
+	return d.handler().WithTrace(ctx)
+}
+
+// AddFilter registers filter with the current handler of the driver, see Handler.AddFilter.
+func (d *SlogDriver) AddFilter(name string, filter FilterAttrs) {
+	d.handler().AddFilter(name, filter)
+}
+
+// RemoveFilter unregisters a filter of the current handler of the driver, see Handler.RemoveFilter.
+func (d *SlogDriver) RemoveFilter(name string) bool {
+	return d.handler().RemoveFilter(name)
+}
+
+// Reconfigure applies ss on top of the current settings of the driver and atomically
+// replaces the handler of the subsequent operations, so the level, sampling or redaction
+// can be changed at runtime, e.g. from an admin endpoint, without recreating the ent
+// client. Transactions already started keep their configuration. The statistics, the
+// asynchronous queue, the background reporters, the audit sink, the record chain, the
+// EXPLAIN capture and the transaction cap keep the configuration given to New, and the
+// filters registered with AddFilter stay registered. The trackers enabled before and
+// after keep their history, such as the windows of the unchanged latency objectives, the
+// fingerprints seen and the operations in flight. The exported Handler methods of the
+// driver, such as Log, use the current handler. It is safe for concurrent use.
+func (d *SlogDriver) Reconfigure(ss ...Setting) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var opt Option
+	WithOptions(d.opt)(&opt)
+	settings.Apply(&opt, ss)
+	build := opt
+	build.async = 0 // The asynchronous queue is inherited.
+	h := makeHandle(d.Dialect(), &build).with(slog.String("database", "driver"))
+	h.inherit(d.handler())
+	d.opt = opt
+	d.live.Store(&h)
+}
+
+// every runs fn every interval until the driver is closed, on scheduler when set
+// or on a dedicated goroutine otherwise.
+func (d *SlogDriver) every(scheduler *Scheduler, interval time.Duration, fn func()) {
+	if scheduler != nil {
+		d.cancels = append(d.cancels, scheduler.Every(interval, fn))
+		return
+	}
+	stop := make(chan struct{})
+	d.cancels = append(d.cancels, func() { close(stop) })
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-stop:
+				return
+			}
+		}
+	}()
 }
 
 // Exec logs its params and calls the underlying init Exec method.
 func (d *SlogDriver) Exec(ctx context.Context, query string, args, v any) error {
-	d.Log(ctx, "Exec", slog.String("query", query), slog.Any("args", args))
-	return d.LogError(ctx, "Exec", d.dri.Exec(ctx, query, args, v))
+	op := d.handler().begin(ctx, "Exec", query)
+	op.logStatement(args)
+	err := d.dri.Exec(op.ctx, op.statement(), args, v)
+	op.recordResult(v, err)
+	return op.end(err)
 }
 
-// ExecContext logs its params and calls the underlying init ExecContext method if it is supported.
+// ExecContext logs its params and calls the underlying init ExecContext method, or its Exec method if it is not supported.
 func (d *SlogDriver) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
-	drv, ok := d.dri.(interface {
-		ExecContext(context.Context, string, ...any) (sql.Result, error)
-	})
-	if !ok {
-		return nil, fmt.Errorf("Driver.ExecContext is not supported")
+	op := d.handler().begin(ctx, "ExecContext", query)
+	op.logStatement(args)
+	drv := d.dri
+	if _, ok := drv.(execContexter); !ok && d.exposed != nil {
+		drv = d.exposed
 	}
-	d.Log(ctx, "ExecContext", slog.String("query", query), slog.Any("args", args))
-	result, err := drv.ExecContext(ctx, query, args...)
-	return result, d.LogError(ctx, "ExecContext", err)
+	result, err := execContext(op.ctx, drv, op.statement(), args)
+	op.recordResult(result, err)
+	return result, op.end(err)
 }
 
 // Query logs its params and calls the underlying init Query method.
 func (d *SlogDriver) Query(ctx context.Context, query string, args, v any) error {
-	d.Log(ctx, "Query", slog.String("query", query), slog.Any("args", args))
-	return d.LogError(ctx, "Query", d.dri.Query(ctx, query, args, v))
+	op := d.handler().begin(ctx, "Query", query)
+	op.logStatement(args)
+	err := d.dri.Query(op.ctx, op.statement(), args, v)
+	op.logResultSet(v, err)
+	op.wrapRows(v, err)
+	return op.end(err)
 }
 
 // QueryContext logs its params and calls the underlying init QueryContext method if it is supported.
-func (d *SlogDriver) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
-	drv, ok := d.dri.(interface {
-		QueryContext(context.Context, string, ...any) (*sql.Rows, error)
-	})
+func (d *SlogDriver) QueryContext(ctx context.Context, query string, args ...any) (*stdsql.Rows, error) {
+	h := d.handler()
+	drv, ok := d.dri.(queryContexter)
+	if !ok {
+		drv, ok = d.exposed.(queryContexter)
+	}
+	if !ok && h.bridgeMethods {
+		drv, ok = bridgeQueryContext(d.dri, d.exposed)
+	}
 	if !ok {
-		return nil, fmt.Errorf("Driver.QueryContext is not supported")
+		return nil, unsupported("Driver.QueryContext")
 	}
-	d.Log(ctx, "QueryContext", slog.String("query", query), slog.Any("args", args))
-	rows, err := drv.QueryContext(ctx, query, args...)
-	return rows, d.LogError(ctx, "QueryContext", err)
+	op := h.begin(ctx, "QueryContext", query)
+	op.logStatement(args)
+	rows, err := drv.QueryContext(op.ctx, op.statement(), args...)
+	op.logResultSet(rows, err)
+	return rows, op.end(err)
 }
 
 // Tx adds an log-id for the transaction and calls the underlying init Tx command.
 func (d *SlogDriver) Tx(ctx context.Context) (dialect.Tx, error) {
-	tx, err := d.dri.Tx(ctx)
+	h := d.handler()
+	op := h.begin(ctx, "Tx", "")
+	tx, err := d.dri.Tx(op.ctx)
 	if err != nil {
+		op.finish(err)
 		return nil, err
 	}
-	id := d.WithTrace(ctx)
-	d.Log(ctx, "Tx started", slog.String("id", id))
-	return &SlogTx{tx: tx, Handler: d.Handler.with(slog.String("database", "tx")), id: id, ctx: ctx}, nil
+	id := h.WithTrace(ctx)
+	op.logLifecycle("Tx started", append([]slog.Attr{slog.String("id", id)}, op.finish(nil)...)...)
+	return d.newTx(ctx, h, tx, id, nil)
 }
 
 // BeginTx adds an log-id for the transaction and calls the underlying init BeginTx command if it is supported.
 func (d *SlogDriver) BeginTx(ctx context.Context, opts *sql.TxOptions) (dialect.Tx, error) {
-	drv, ok := d.dri.(interface {
-		BeginTx(context.Context, *sql.TxOptions) (dialect.Tx, error)
-	})
+	h := d.handler()
+	drv, ok := d.dri.(beginTxer)
+	if !ok {
+		drv, ok = d.exposed.(beginTxer)
+	}
+	if !ok && h.bridgeMethods {
+		drv, ok = bridgeBeginTx(d.dri, d.exposed), true
+	}
 	if !ok {
-		return nil, fmt.Errorf("Driver.BeginTx is not supported")
+		return nil, unsupported("Driver.BeginTx")
 	}
-	tx, err := drv.BeginTx(ctx, opts)
+	op := h.begin(ctx, "BeginTx", "")
+	tx, err := drv.BeginTx(op.ctx, opts)
 	if err != nil {
-		return nil, d.LogError(ctx, "BeginTx", err)
+		return nil, op.end(err)
+	}
+	id := h.WithTrace(ctx)
+	attrs := []slog.Attr{slog.String("id", id)}
+	if opts != nil {
+		attrs = append(attrs, slog.String("isolation", opts.Isolation.String()), slog.Bool("read_only", opts.ReadOnly))
+	}
+	op.logLifecycle("BeginTx started", append(attrs, op.finish(nil)...)...)
+	return d.newTx(ctx, h, tx, id, opts)
+}
+
+// newTx wraps tx in a SlogTx logging with dh, whose records all carry the transaction id.
+func (d *SlogDriver) newTx(ctx context.Context, dh *Handler, tx dialect.Tx, id string, opts *sql.TxOptions) (dialect.Tx, error) {
+	h := dh.with(slog.String("database", "tx"), slog.String("id", id))
+	// Statements inside a transaction always run on the connection acquired by the transaction.
+	h.connSource = false
+	h.txID = id
+	if h.nPlusOne > 0 {
+		h.txCounts = newQueryCounter()
 	}
-	id := d.WithTrace(ctx)
-	d.Log(ctx, "BeginTx started", slog.String("id", id))
-	return &SlogTx{tx: tx, Handler: d.Handler.with(slog.String("database", "tx")), id: id, ctx: ctx}, nil
+	if h.audit != nil {
+		h.audited = new(atomic.Bool)
+	}
+	if h.readOnlyCheck && opts != nil && opts.ReadOnly {
+		h.readOnly = new(readOnlyTx)
+	}
+	if h.shedder != nil && !h.shedder.admit(id) {
+		h.txSummary = &txSummary{start: h.now()}
+	}
+	t := &SlogTx{tx: tx, Handler: h, id: id, ctx: ctx}
+	if h.sessionTags {
+		if err := t.tagSession(ctx); err != nil {
+			_ = tx.Rollback()
+			if h.shedder != nil {
+				// The transaction never reaches Commit or Rollback, which release its slot.
+				h.shedder.release(h.txSummary == nil)
+			}
+			return nil, err
+		}
+	}
+	t.tracker = dh.trackTx(ctx, id)
+	return t, nil
 }
 
 // SlogTx is a transaction implementation that logs all transaction operations.
@@ -107,56 +370,82 @@ type SlogTx struct {
 	tx  dialect.Tx      // underlying transaction.
 	id  string          // transaction logging id.
 	ctx context.Context // underlying transaction context.
+
+	tracker  *txTracker  // records the open transactions of the context, nil unless tracked.
+	released atomic.Bool // whether the slot of WithMaxConcurrentLoggedTx was released.
+
+	spMu       sync.Mutex  // guards savepoints.
+	savepoints []savepoint // savepoints set through the transaction, oldest first.
+}
+
+// Unwrap returns the underlying transaction, following the contract of SlogDriver.Unwrap.
+// Operations run directly on the returned transaction are not logged.
+func (d *SlogTx) Unwrap() dialect.Tx {
+	return d.tx
 }
 
 // Exec logs its params and calls the underlying transaction Exec method.
 func (d *SlogTx) Exec(ctx context.Context, query string, args, v any) error {
-	d.Log(ctx, "Exec", slog.String("id", d.id), slog.String("query", query), slog.Any("args", args))
-	return d.LogError(ctx, "Exec", d.tx.Exec(ctx, query, args, v))
+	op := d.begin(ctx, "Exec", query)
+	op.logStatement(args)
+	err := d.tx.Exec(op.ctx, op.statement(), args, v)
+	op.recordResult(v, err)
+	return op.end(err)
 }
 
-// ExecContext logs its params and calls the underlying transaction ExecContext method if it is supported.
+// ExecContext logs its params and calls the underlying transaction ExecContext method, or its Exec method if it is not supported.
 func (d *SlogTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
-	drv, ok := d.tx.(interface {
-		ExecContext(context.Context, string, ...any) (sql.Result, error)
-	})
-	if !ok {
-		return nil, fmt.Errorf("Tx.ExecContext is not supported")
-	}
-	d.Log(ctx, "ExecContext", slog.String("id", d.id), slog.String("query", query), slog.Any("args", args))
-	result, err := drv.ExecContext(ctx, query, args...)
-
-	return result, d.LogError(ctx, "ExecContext", err)
+	op := d.begin(ctx, "ExecContext", query)
+	op.logStatement(args)
+	result, err := execContext(op.ctx, d.tx, op.statement(), args)
+	op.recordResult(result, err)
+	return result, op.end(err)
 }
 
 // Query logs its params and calls the underlying transaction Query method.
 func (d *SlogTx) Query(ctx context.Context, query string, args, v any) error {
-	d.Log(ctx, "Query", slog.String("id", d.id), slog.String("query", query), slog.Any("args", args))
-	return d.LogError(ctx, "Query", d.tx.Query(ctx, query, args, v))
+	op := d.begin(ctx, "Query", query)
+	op.logStatement(args)
+	err := d.tx.Query(op.ctx, op.statement(), args, v)
+	op.logResultSet(v, err)
+	op.wrapRows(v, err)
+	return op.end(err)
 }
 
 // QueryContext logs its params and calls the underlying transaction QueryContext method if it is supported.
-func (d *SlogTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
-	drv, ok := d.tx.(interface {
-		QueryContext(context.Context, string, ...any) (*sql.Rows, error)
-	})
+func (d *SlogTx) QueryContext(ctx context.Context, query string, args ...any) (*stdsql.Rows, error) {
+	drv, ok := d.tx.(queryContexter)
+	if !ok && d.bridgeMethods {
+		drv, ok = bridgeTxQueryContext(d.tx)
+	}
 	if !ok {
-		return nil, fmt.Errorf("Tx.QueryContext is not supported")
+		return nil, unsupported("Tx.QueryContext")
 	}
-	d.Log(ctx, "QueryContext", slog.String("id", d.id), slog.String("query", query), slog.Any("args", args))
-	rows, err := drv.QueryContext(ctx, query, args...)
-
-	return rows, d.LogError(ctx, "QueryContext", err)
+	op := d.begin(ctx, "QueryContext", query)
+	op.logStatement(args)
+	rows, err := drv.QueryContext(op.ctx, op.statement(), args...)
+	op.logResultSet(rows, err)
+	return rows, op.end(err)
 }
 
 // Commit logs this step and calls the underlying transaction Commit method.
 func (d *SlogTx) Commit() error {
-	d.Log(d.ctx, "Commit", slog.String("id", d.id))
-	return d.LogError(d.ctx, "Commit", d.tx.Commit())
+	op := d.begin(d.ctx, "Commit", "")
+	op.logLifecycle("Commit")
+	defer d.tracker.done(d.id)
+	defer d.endShed(op.ctx, "commit")
+	err := op.end(d.tx.Commit())
+	op.auditTx("commit", err)
+	return err
 }
 
 // Rollback logs this step and calls the underlying transaction Rollback method.
 func (d *SlogTx) Rollback() error {
-	d.Log(d.ctx, "Rollback", slog.String("id", d.id))
-	return d.LogError(d.ctx, "Rollback", d.tx.Rollback())
+	op := d.begin(d.ctx, "Rollback", "")
+	op.logLifecycle("Rollback")
+	defer d.tracker.done(d.id)
+	defer d.endShed(op.ctx, "rollback")
+	err := op.end(d.tx.Rollback())
+	op.auditTx("rollback", err)
+	return err
 }
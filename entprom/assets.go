@@ -0,0 +1,148 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entprom exposes entslog driver operations as Prometheus metrics.
+package entprom
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Thresholds of the generated alert rules.
+const (
+	alertErrorRatio  = 0.05 // share of failed operations above which EntErrorRateHigh fires.
+	alertP99Duration = 1.0  // 99th percentile duration, in seconds, above which EntLatencyHigh fires.
+)
+
+// metricNames holds the names and the constant label selector of the metrics of a collector.
+type metricNames struct {
+	total    string
+	duration string
+	selector string // label matchers of the constant labels, e.g. db="main".
+}
+
+func namesOf(ss []Setting) metricNames {
+	o := defaultOption
+	for _, s := range ss {
+		s(&o)
+	}
+	matchers := make([]string, 0, len(o.constLabels))
+	for _, name := range slices.Sorted(maps.Keys(o.constLabels)) {
+		matchers = append(matchers, name+"="+strconv.Quote(o.constLabels[name]))
+	}
+	return metricNames{
+		total:    prometheus.BuildFQName(o.namespace, o.subsystem, operationsTotal),
+		duration: prometheus.BuildFQName(o.namespace, o.subsystem, operationDuration),
+		selector: strings.Join(matchers, ","),
+	}
+}
+
+// match returns the selector of the constant labels extended with matchers.
+func (n metricNames) match(matchers ...string) string {
+	if n.selector != "" {
+		matchers = append([]string{n.selector}, matchers...)
+	}
+	return "{" + strings.Join(matchers, ",") + "}"
+}
+
+func (n metricNames) rate() string {
+	return fmt.Sprintf("sum by (%s) (rate(%s%s[$__rate_interval]))", labelOperation, n.total, n.match())
+}
+
+func (n metricNames) errorRatio(window string) string {
+	return fmt.Sprintf("sum by (%[1]s) (rate(%[2]s%[3]s[%[5]s])) / sum by (%[1]s) (rate(%[2]s%[4]s[%[5]s]))",
+		labelOperation, n.total, n.match(labelResult+`="error"`), n.match(), window)
+}
+
+func (n metricNames) quantile(q float64, window string) string {
+	return fmt.Sprintf("histogram_quantile(%g, sum by (%s, le) (rate(%s_bucket%s[%s])))",
+		q, labelOperation, n.duration, n.match(), window)
+}
+
+// Dashboard returns the JSON model of a Grafana dashboard charting the metrics of a
+// collector created with the same settings: the operation rate, the error ratio and the
+// duration percentiles by operation. The Prometheus data source is selected by the
+// dashboard's datasource variable.
+func Dashboard(ss ...Setting) ([]byte, error) {
+	n := namesOf(ss)
+	panel := func(id int, title, unit string, x, y int, exprs map[string]string) map[string]any {
+		targets := make([]map[string]any, 0, len(exprs))
+		for _, legend := range slices.Sorted(maps.Keys(exprs)) {
+			targets = append(targets, map[string]any{
+				"datasource":   map[string]any{"type": "prometheus", "uid": "${datasource}"},
+				"expr":         exprs[legend],
+				"legendFormat": legend,
+				"refId":        string(rune('A' + len(targets))),
+			})
+		}
+		return map[string]any{
+			"id":          id,
+			"type":        "timeseries",
+			"title":       title,
+			"datasource":  map[string]any{"type": "prometheus", "uid": "${datasource}"},
+			"gridPos":     map[string]any{"h": 8, "w": 12, "x": x, "y": y},
+			"fieldConfig": map[string]any{"defaults": map[string]any{"unit": unit}, "overrides": []any{}},
+			"targets":     targets,
+		}
+	}
+	dashboard := map[string]any{
+		"title":         "ent database operations",
+		"uid":           fmt.Sprintf("entslog-%08x", crc32.ChecksumIEEE([]byte(n.total+n.selector))),
+		"tags":          []string{"ent", "entslog"},
+		"schemaVersion": 39,
+		"time":          map[string]any{"from": "now-6h", "to": "now"},
+		"refresh":       "30s",
+		"templating": map[string]any{"list": []any{map[string]any{
+			"name":  "datasource",
+			"label": "Data source",
+			"type":  "datasource",
+			"query": "prometheus",
+		}}},
+		"panels": []any{
+			panel(1, "Operations per second", "ops", 0, 0, map[string]string{
+				"{{operation}}": n.rate(),
+			}),
+			panel(2, "Error ratio", "percentunit", 12, 0, map[string]string{
+				"{{operation}}": n.errorRatio("$__rate_interval"),
+			}),
+			panel(3, "Duration p50", "s", 0, 8, map[string]string{
+				"{{operation}}": n.quantile(0.5, "$__rate_interval"),
+			}),
+			panel(4, "Duration p99", "s", 12, 8, map[string]string{
+				"{{operation}}": n.quantile(0.99, "$__rate_interval"),
+			}),
+		},
+	}
+	return json.MarshalIndent(dashboard, "", "  ")
+}
+
+// AlertRules returns a Prometheus rule file alerting on the metrics of a collector created
+// with the same settings: EntErrorRateHigh fires when more than 5% of the operations of a
+// kind fail over 5 minutes, EntLatencyHigh when their 99th percentile duration exceeds one
+// second over 10 minutes.
+func AlertRules(ss ...Setting) []byte {
+	n := namesOf(ss)
+	var b strings.Builder
+	b.WriteString("groups:\n")
+	b.WriteString("  - name: entslog\n")
+	b.WriteString("    rules:\n")
+	rule := func(alert, expr, duration, severity, summary string) {
+		fmt.Fprintf(&b, "      - alert: %s\n", alert)
+		fmt.Fprintf(&b, "        expr: %s\n", strconv.Quote(expr))
+		fmt.Fprintf(&b, "        for: %s\n", duration)
+		fmt.Fprintf(&b, "        labels:\n          severity: %s\n", severity)
+		fmt.Fprintf(&b, "        annotations:\n          summary: %s\n", strconv.Quote(summary))
+	}
+	rule("EntErrorRateHigh", fmt.Sprintf("%s > %g", n.errorRatio("5m"), alertErrorRatio), "5m", "warning",
+		"More than 5% of the {{ $labels.operation }} database operations fail.")
+	rule("EntLatencyHigh", fmt.Sprintf("%s > %g", n.quantile(0.99, "5m"), alertP99Duration), "10m", "warning",
+		"The p99 duration of {{ $labels.operation }} database operations exceeds 1s.")
+	return []byte(b.String())
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Command entpromgen writes the Grafana dashboard and the Prometheus alert rules matching
+// the metrics of an entprom collector, so they can be regenerated with go generate:
+//
+//	//go:generate go run github.com/origadmin/entslog/v3/entprom/cmd/entpromgen -namespace app -dashboard dashboard.json -rules rules.yml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/origadmin/entslog/v3/entprom"
+)
+
+// labelFlags collects the repeated -label name=value flags.
+type labelFlags prometheus.Labels
+
+func (l labelFlags) String() string {
+	return fmt.Sprint(prometheus.Labels(l))
+}
+
+func (l labelFlags) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("label %q is not name=value", s)
+	}
+	l[name] = value
+	return nil
+}
+
+func main() {
+	var (
+		namespace = flag.String("namespace", "", "namespace of the metric names")
+		subsystem = flag.String("subsystem", "ent", "subsystem of the metric names")
+		dashboard = flag.String("dashboard", "", "file the Grafana dashboard JSON is written to")
+		rules     = flag.String("rules", "", "file the Prometheus alert rules are written to")
+		labels    = labelFlags{}
+	)
+	flag.Var(labels, "label", "constant label of the metrics, as name=value, may be repeated")
+	flag.Parse()
+	if *dashboard == "" && *rules == "" {
+		fmt.Fprintln(os.Stderr, "entpromgen: at least one of -dashboard and -rules is required")
+		os.Exit(2)
+	}
+	ss := []entprom.Setting{entprom.WithNamespace(*namespace), entprom.WithSubsystem(*subsystem)}
+	if len(labels) > 0 {
+		ss = append(ss, entprom.WithConstLabels(prometheus.Labels(labels)))
+	}
+	if *dashboard != "" {
+		data, err := entprom.Dashboard(ss...)
+		if err == nil {
+			err = os.WriteFile(*dashboard, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "entpromgen:", err)
+			os.Exit(1)
+		}
+	}
+	if *rules != "" {
+		if err := os.WriteFile(*rules, entprom.AlertRules(ss...), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, "entpromgen:", err)
+			os.Exit(1)
+		}
+	}
+}
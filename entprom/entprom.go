@@ -0,0 +1,129 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entprom exposes entslog driver operations as Prometheus metrics.
+package entprom
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	entslog "github.com/origadmin/entslog/v3"
+)
+
+type (
+	// Option defines configuration options for the collector.
+	Option struct {
+		namespace   string            // Namespace is prepended to the metric names.
+		subsystem   string            // Subsystem is prepended to the metric names after the namespace.
+		constLabels prometheus.Labels // ConstLabels are attached to every metric.
+		buckets     []float64         // Buckets are the upper bounds of the duration histogram, in seconds.
+	}
+	// Setting is a function that modifies the collector options.
+	Setting = func(*Option)
+)
+
+// Names of the metrics and their labels, shared with the generated dashboards and alert rules.
+const (
+	operationsTotal   = "operations_total"
+	operationDuration = "operation_duration_seconds"
+	labelOperation    = "operation"
+	labelResult       = "result"
+)
+
+// defaultOption provides the default configuration options for the collector.
+var defaultOption = Option{
+	subsystem: "ent",                 // Metrics are named ent_*.
+	buckets:   prometheus.DefBuckets, // Defaults to the Prometheus default buckets.
+}
+
+// WithNamespace sets the namespace of the metric names.
+func WithNamespace(namespace string) Setting {
+	return func(o *Option) {
+		o.namespace = namespace
+	}
+}
+
+// WithSubsystem sets the subsystem of the metric names, defaults to "ent".
+func WithSubsystem(subsystem string) Setting {
+	return func(o *Option) {
+		o.subsystem = subsystem
+	}
+}
+
+// WithConstLabels sets labels attached to every metric, e.g. the database name.
+func WithConstLabels(labels prometheus.Labels) Setting {
+	return func(o *Option) {
+		o.constLabels = labels
+	}
+}
+
+// WithBuckets sets the upper bounds of the statement duration histogram, in seconds.
+func WithBuckets(buckets ...float64) Setting {
+	return func(o *Option) {
+		o.buckets = buckets
+	}
+}
+
+// Collector is a prometheus.Collector fed by an entslog driver. It counts operations by
+// operation name and result, and records a histogram of their durations.
+//
+//	c := entprom.NewCollector()
+//	prometheus.MustRegister(c)
+//	drv := entslog.New(drv, entslog.WithObserver(c))
+type Collector struct {
+	total    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+var (
+	_ prometheus.Collector = (*Collector)(nil)
+	_ entslog.Observer     = (*Collector)(nil)
+)
+
+// NewCollector returns a new collector, it must be registered before metrics are exposed.
+func NewCollector(ss ...Setting) *Collector {
+	o := defaultOption
+	for _, s := range ss {
+		s(&o)
+	}
+	return &Collector{
+		total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.namespace,
+			Subsystem:   o.subsystem,
+			Name:        operationsTotal,
+			Help:        "Total number of driver operations by operation and result.",
+			ConstLabels: o.constLabels,
+		}, []string{labelOperation, labelResult}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   o.namespace,
+			Subsystem:   o.subsystem,
+			Name:        operationDuration,
+			Help:        "Duration of driver operations in seconds by operation and result.",
+			ConstLabels: o.constLabels,
+			Buckets:     o.buckets,
+		}, []string{labelOperation, labelResult}),
+	}
+}
+
+// Observe implements entslog.Observer.
+func (c *Collector) Observe(_ context.Context, e entslog.Event) {
+	result := "success"
+	if e.Err != nil {
+		result = "error"
+	}
+	c.total.WithLabelValues(e.Op, result).Inc()
+	c.duration.WithLabelValues(e.Op, result).Observe(e.Duration.Seconds())
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.total.Describe(ch)
+	c.duration.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.total.Collect(ch)
+	c.duration.Collect(ch)
+}
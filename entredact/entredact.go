@@ -0,0 +1,141 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entredact masks the parts of logged queries and arguments matching regular expressions.
+package entredact
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	entslog "github.com/origadmin/entslog/v3"
+)
+
+type (
+	// Option defines configuration options for the redactor.
+	Option struct {
+		mask string // Mask replaces every match.
+	}
+	// Setting is a function that modifies the redactor options.
+	Setting = func(*Option)
+)
+
+// defaultOption provides the default configuration options for the redactor.
+var defaultOption = Option{
+	mask: "[REDACTED]", // Matches are replaced with [REDACTED].
+}
+
+// Common patterns, to be combined with application specific ones. Email, CardNumber and
+// Phone are the patterns of the entslog detectors, so queries and arguments are masked alike.
+var (
+	// Email matches email addresses.
+	Email = entslog.EmailDetector().Pattern
+	// CardNumber matches payment card numbers of 13 to 19 digits, optionally grouped by
+	// spaces or dashes. Only numbers passing the Luhn check are masked.
+	CardNumber = cardNumber.Pattern
+	// Phone matches international phone numbers, such as +1 555 123 4567.
+	Phone = entslog.PhoneDetector().Pattern
+	// Bearer matches bearer tokens, such as the values of Authorization headers.
+	Bearer = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]+=*`)
+)
+
+// cardNumber is the detector CardNumber is taken from.
+var cardNumber = entslog.CardNumberDetector()
+
+// validators confirms the matches of the common patterns, such as the Luhn check of card numbers.
+var validators = map[*regexp.Regexp]func(match string) bool{
+	CardNumber: cardNumber.Valid,
+}
+
+// WithMask sets the text replacing every match, defaults to "[REDACTED]".
+func WithMask(mask string) Setting {
+	return func(o *Option) {
+		o.mask = mask
+	}
+}
+
+// Redactor replaces the parts of query texts and stringified bind arguments matching
+// its patterns with a mask.
+//
+//	r := entredact.New([]*regexp.Regexp{entredact.Email, entredact.CardNumber})
+//	drv := entslog.New(drv, r.Setting())
+type Redactor struct {
+	patterns []*regexp.Regexp
+	mask     string
+}
+
+// New returns a redactor masking the matches of patterns.
+func New(patterns []*regexp.Regexp, ss ...Setting) *Redactor {
+	o := defaultOption
+	for _, s := range ss {
+		s(&o)
+	}
+	return &Redactor{patterns: patterns, mask: o.mask}
+}
+
+// Compile returns a redactor masking the matches of the regular expressions exprs,
+// or an error when one does not compile.
+func Compile(exprs []string, ss ...Setting) (*Redactor, error) {
+	patterns := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("entredact: %w", err)
+		}
+		patterns = append(patterns, re)
+	}
+	return New(patterns, ss...), nil
+}
+
+// Redact returns s with every match of the patterns replaced by the mask.
+func (r *Redactor) Redact(s string) string {
+	for _, re := range r.patterns {
+		valid, ok := validators[re]
+		if !ok {
+			s = re.ReplaceAllLiteralString(s, r.mask)
+			continue
+		}
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			if !valid(match) {
+				return match
+			}
+			return r.mask
+		})
+	}
+	return s
+}
+
+// Query implements entslog.QueryFilterFunc.
+func (r *Redactor) Query(_ context.Context, query string) string {
+	return r.Redact(query)
+}
+
+// Arg implements entslog.ArgFilterFunc. Strings, byte slices and fmt.Stringer values
+// are matched, arguments with a match are logged as their redacted string, the others
+// unchanged.
+func (r *Redactor) Arg(_ context.Context, _ int, value any) any {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		return value
+	}
+	if redacted := r.Redact(s); redacted != s {
+		return redacted
+	}
+	return value
+}
+
+// Setting returns the entslog setting redacting the queries and the arguments of the
+// driver. It replaces the filters set by entslog.WithQueryFilter and entslog.WithArgFilter.
+func (r *Redactor) Setting() entslog.Setting {
+	return func(o *entslog.Option) {
+		entslog.WithQueryFilter(r.Query)(o)
+		entslog.WithArgFilter(r.Arg)(o)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entredact masks the parts of logged queries and arguments matching regular expressions.
+package entredact
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func TestRedactorQuery(t *testing.T) {
+	r := New([]*regexp.Regexp{Email, CardNumber, Phone, Bearer})
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"email", "SELECT * FROM u WHERE email = 'a.b@example.com'", "SELECT * FROM u WHERE email = '[REDACTED]'"},
+		{"card number", "INSERT INTO p VALUES ('4111 1111 1111 1111')", "INSERT INTO p VALUES ('[REDACTED]')"},
+		{"card number failing luhn", "SELECT * FROM o WHERE id = 4111111111111112", "SELECT * FROM o WHERE id = 4111111111111112"},
+		{"phone", "UPDATE u SET phone = '+1 555 123 4567'", "UPDATE u SET phone = '[REDACTED]'"},
+		{"bearer", "INSERT INTO l VALUES ('Bearer abc.def-123')", "INSERT INTO l VALUES ('[REDACTED]')"},
+		{"several", "SELECT 'x@y.io', 'z@y.io'", "SELECT '[REDACTED]', '[REDACTED]'"},
+		{"no match", "SELECT * FROM u WHERE id = ?", "SELECT * FROM u WHERE id = ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Query(context.Background(), tt.query); got != tt.want {
+				t.Errorf("Query(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorArg(t *testing.T) {
+	r := New([]*regexp.Regexp{Email}, WithMask("***"))
+	stringer := &url.URL{Scheme: "mailto", Opaque: "a@example.com"}
+	plain := &url.URL{Scheme: "https", Host: "example.com"}
+	tests := []struct {
+		name  string
+		value any
+		want  any
+	}{
+		{"string", "a@example.com", "***"},
+		{"bytes", []byte("to: a@example.com"), "to: ***"},
+		{"stringer", stringer, "mailto:***"},
+		{"string without match", "alice", "alice"},
+		{"stringer without match", plain, plain},
+		{"other type", 42, 42},
+		{"nil", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Arg(context.Background(), 0, tt.value); got != tt.want {
+				t.Errorf("Arg(%v) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	// Byte slices without a match are returned as is.
+	value := []byte("alice")
+	if got, ok := r.Arg(context.Background(), 0, value).([]byte); !ok || &got[0] != &value[0] {
+		t.Errorf("Arg(%q) = %v, want the same slice", value, got)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name  string
+		exprs []string
+		in    string
+		want  string
+		err   bool
+	}{
+		{"patterns", []string{`secret-\w+`, `\d{4}`}, "secret-abc 1234", "[REDACTED] [REDACTED]", false},
+		{"no patterns", nil, "secret-abc", "secret-abc", false},
+		{"bad regex", []string{`secret-\w+`, `(unclosed`}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Compile(tt.exprs)
+			if tt.err {
+				if err == nil || r != nil {
+					t.Fatalf("Compile(%q) = %v, %v, want an error", tt.exprs, r, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.Redact(tt.in); got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Event describes a completed driver operation.
+type Event struct {
+	Op          string        // Op is the operation name, e.g. "Exec", "QueryContext" or "Commit".
+	Query       string        // Query is the statement sent to the driver, empty for transaction control.
+	Fingerprint string        // Fingerprint is the fingerprint of the statement, empty for transaction control.
+	Class       string        // Class is the statement class (ClassSelect, ClassDDL, ...), empty for transaction control.
+	Tables      []string      // Tables are the tables the statement touches, empty for transaction control.
+	TxID        string        // TxID is the transaction logging id, empty outside transactions.
+	Start       time.Time     // Start is the time the operation started.
+	Duration    time.Duration // Duration is the time spent in the underlying driver.
+	Err         error         // Err is the error returned by the underlying driver.
+	ID          string        // ID orders the events of the process, empty unless WithEventClock is set.
+}
+
+// Observer receives an Event for every completed operation, e.g. to feed metrics.
+// Observe is called synchronously on the query path and must be safe for concurrent use.
+type Observer interface {
+	Observe(ctx context.Context, e Event)
+}
+
+// ObserverFunc adapts an ordinary function to the Observer interface.
+type ObserverFunc func(ctx context.Context, e Event)
+
+// Observe calls f(ctx, e).
+func (f ObserverFunc) Observe(ctx context.Context, e Event) {
+	f(ctx, e)
+}
+
+// LevelFunc computes the level of the record reporting the outcome of an operation.
+type LevelFunc func(ctx context.Context, e Event) slog.Level
+
+// DurationLevel is a step of LevelByDuration: the operations faster than Below are logged at Level.
+type DurationLevel struct {
+	Below time.Duration // Below is the exclusive upper bound of the durations of the step.
+	Level slog.Level    // Level is the level of the operations of the step.
+}
+
+// LevelByDuration returns a LevelFunc, for WithLevelFunc, logging the operations at the
+// level of the first step whose bound their duration is below, and the slower ones at
+// above. Failed operations are logged at Error level. For instance, Info below 50ms,
+// Warn below 500ms and Error otherwise:
+//
+//	entslog.WithLevelFunc(entslog.LevelByDuration(slog.LevelError,
+//		entslog.DurationLevel{Below: 50 * time.Millisecond, Level: slog.LevelInfo},
+//		entslog.DurationLevel{Below: 500 * time.Millisecond, Level: slog.LevelWarn},
+//	))
+func LevelByDuration(above slog.Level, steps ...DurationLevel) LevelFunc {
+	return func(_ context.Context, e Event) slog.Level {
+		if e.Err != nil {
+			return slog.LevelError
+		}
+		for _, step := range steps {
+			if e.Duration < step.Below {
+				return step.Level
+			}
+		}
+		return above
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql"
+)
+
+// explainTimeout bounds the duration of an EXPLAIN statement.
+const explainTimeout = 5 * time.Second
+
+// analyzeInterval is the minimum interval between two EXPLAIN ANALYZE statements.
+const analyzeInterval = time.Second
+
+// explainer captures the plans of slow SELECT statements through the underlying driver.
+type explainer struct {
+	dri       dialect.Driver // underlying driver, so EXPLAIN statements are not logged themselves.
+	interval  time.Duration  // minimum interval between two EXPLAIN statements.
+	last      atomic.Int64   // unix nanoseconds of the last EXPLAIN statement.
+	analyze   bool           // whether statements are executed by EXPLAIN ANALYZE in a transaction rolled back.
+	threshold time.Duration  // duration above which statements are analyzed.
+}
+
+// allow reports whether an EXPLAIN statement may run at now, reserving the slot when it may.
+func (e *explainer) allow(now time.Time) bool {
+	last := e.last.Load()
+	if last != 0 && now.Sub(time.Unix(0, last)) < e.interval {
+		return false
+	}
+	return e.last.CompareAndSwap(last, now.UnixNano())
+}
+
+// explainStatement returns the statement showing the plan of query, or false when the dialect has none.
+func explainStatement(name, query string) (string, bool) {
+	switch name {
+	case dialect.Postgres, dialect.MySQL:
+		return "EXPLAIN " + query, true
+	case dialect.SQLite:
+		return "EXPLAIN QUERY PLAN " + query, true
+	}
+	return "", false
+}
+
+// explainSlow captures the plan of a slow SELECT statement in the background and logs it
+// in a follow-up record. Statements touching sensitive tables are never explained, as
+// plans may contain the values of their conditions.
+func (op *operation) explainSlow(err error) {
+	if op.explain == nil || err != nil || op.class != ClassSelect || !op.slow() || op.sensitive {
+		return
+	}
+	query, ok := explainStatement(op.dialect, op.query)
+	if !ok || !op.explain.allow(time.Now()) {
+		return
+	}
+	op.capturePlan(op.explain, query, explainTimeout)
+}
+
+// analyzeSlow captures the plan of a slow SELECT statement with EXPLAIN ANALYZE, which
+// executes it again, in the background. The statement runs in a transaction that is
+// always rolled back, read-only when the driver supports it, and is canceled after twice
+// the duration of the original execution. Statements that write, lock rows or touch
+// sensitive tables are never analyzed.
+func (op *operation) analyzeSlow(err error) {
+	if op.analyze == nil || err != nil || op.took <= op.analyze.threshold || !analyzable(op.class, op.tokens) ||
+		op.sensitive {
+		return
+	}
+	query, ok := analyzeStatement(op.dialect, op.query)
+	if !ok || !op.analyze.allow(time.Now()) {
+		return
+	}
+	op.capturePlan(op.analyze, query, min(2*op.took, explainTimeout))
+}
+
+// capturePlan runs the EXPLAIN statement query in the background and logs the plan.
+// The goroutine only captures copies of the fields of op, which is not safe for
+// concurrent use, e.g. by the capture of the other explainer.
+func (op *operation) capturePlan(e *explainer, query string, timeout time.Duration) {
+	h, ctx, name, args := op.Handler, context.WithoutCancel(op.ctx), op.name, op.args
+	attrs := append(op.subjectAttrs(), slog.Duration("duration", op.took))
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		plan, err := e.plan(ctx, query, args)
+		if err != nil {
+			h.logAt(ctx, slog.LevelDebug, name+" explain", slog.Any("error", err))
+			return
+		}
+		attrs := append(attrs, slog.Any("plan", plan))
+		if e.analyze {
+			attrs = append(attrs, slog.Bool("analyze", true))
+		}
+		h.logAt(ctx, slog.LevelWarn, name+" plan", attrs...)
+	}()
+}
+
+// analyzeStatement returns the statement executing query and showing its plan with
+// timings, or false when the dialect has none.
+func analyzeStatement(name, query string) (string, bool) {
+	switch name {
+	case dialect.Postgres:
+		return "EXPLAIN (ANALYZE, BUFFERS) " + query, true
+	case dialect.MySQL:
+		return "EXPLAIN ANALYZE " + query, true
+	}
+	return "", false
+}
+
+// analyzable reports whether a query, given its class and significant tokens, is a single
+// SELECT statement that neither writes, through data-modifying common table expressions
+// or SELECT INTO, nor locks rows.
+func analyzable(class string, tokens []token) bool {
+	if class != ClassSelect {
+		return false
+	}
+	for i, t := range tokens {
+		if t.text == ";" && i < len(tokens)-1 {
+			return false
+		}
+		if t.kind != tokWord {
+			continue
+		}
+		switch strings.ToUpper(t.text) {
+		case "INSERT", "UPDATE", "DELETE", "MERGE", "INTO", "LOCK", "SHARE":
+			// UPDATE and SHARE also reject the locking clauses, such as FOR UPDATE and LOCK IN SHARE MODE.
+			return false
+		}
+	}
+	return true
+}
+
+// plan runs query and returns its result set, one line per row. EXPLAIN ANALYZE
+// statements run in a transaction that is rolled back.
+func (e *explainer) plan(ctx context.Context, query string, args any) ([]string, error) {
+	if !e.analyze {
+		return planOf(ctx, e.dri, query, args)
+	}
+	var (
+		tx  dialect.Tx
+		err error
+	)
+	if drv, ok := e.dri.(interface {
+		BeginTx(context.Context, *sql.TxOptions) (dialect.Tx, error)
+	}); ok {
+		tx, err = drv.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	} else {
+		tx, err = e.dri.Tx(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	return planOf(ctx, tx, query, args)
+}
+
+// planOf runs query on q and returns its result set, one line per row.
+func planOf(ctx context.Context, q dialect.ExecQuerier, query string, args any) ([]string, error) {
+	var rows sql.Rows
+	if err := q.Query(ctx, query, args, &rows); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var plan []string
+	for rows.Next() {
+		values := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		plan = append(plan, planLine(columns, values))
+	}
+	return plan, rows.Err()
+}
+
+// planLine formats a row of a plan, which is the value itself for single-column plans
+// such as the ones of Postgres, or name=value pairs otherwise.
+func planLine(columns []string, values []any) string {
+	text := func(v any) string {
+		if b, ok := v.([]byte); ok {
+			return string(b)
+		}
+		return fmt.Sprint(v)
+	}
+	if len(values) == 1 {
+		return text(values[0])
+	}
+	parts := make([]string, 0, len(values))
+	for i, v := range values {
+		if v != nil {
+			parts = append(parts, columns[i]+"="+text(v))
+		}
+	}
+	return strings.Join(parts, " ")
+}
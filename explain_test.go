@@ -0,0 +1,132 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	stdsql "database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql"
+)
+
+// timedDriver is a *sql.Driver whose queries, other than EXPLAIN statements, take the
+// duration in took as measured by clock.
+type timedDriver struct {
+	*sql.Driver
+	clock *testClock
+	took  time.Duration
+}
+
+func newTimedDriver(name string, took time.Duration) *timedDriver {
+	return &timedDriver{Driver: sql.OpenDB(name, stdsql.OpenDB(testConnector{})), clock: newTestClock(), took: took}
+}
+
+func (d *timedDriver) Query(ctx context.Context, query string, args, v any) error {
+	if !strings.HasPrefix(query, "EXPLAIN") {
+		d.clock.Advance(d.took)
+	}
+	return d.Driver.Query(ctx, query, args, v)
+}
+
+// waitRecords waits for n records whose message is msg.
+func waitRecords(t *testing.T, log *testLog, msg string, n int) []map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		records := log.Records(t, msg)
+		if len(records) >= n || time.Now().After(deadline) {
+			return records
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSlowQueryExplainAndAnalyze(t *testing.T) {
+	var log testLog
+	dri := newTimedDriver(dialect.Postgres, 500*time.Millisecond)
+	drv := New(dri, WithLogger(log.Logger()), WithClock(dri.clock.Now), Development(),
+		WithSlowThreshold(100*time.Millisecond), WithSlowQueryExplain(time.Minute), WithExplainAnalyze(100*time.Millisecond))
+	var rows sql.Rows
+	if err := drv.Query(context.Background(), "SELECT * FROM t WHERE id = $1", []any{1}, &rows); err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	records := waitRecords(t, &log, "Query plan", 2)
+	if len(records) != 2 {
+		t.Fatalf("got %d plan records, want 2", len(records))
+	}
+	var analyzed int
+	for _, record := range records {
+		if record["fingerprint"] != fingerprint("SELECT * FROM t WHERE id = $1") || record["op"] != ClassSelect {
+			t.Errorf("plan record = %v", record)
+		}
+		if got := time.Duration(record["duration"].(float64)); got != 500*time.Millisecond {
+			t.Errorf("duration = %v, want 500ms", got)
+		}
+		if record["analyze"] == true {
+			analyzed++
+		}
+	}
+	if analyzed != 1 {
+		t.Errorf("got %d analyzed plans, want 1", analyzed)
+	}
+}
+
+func TestSlowQueryExplainSkips(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		took  time.Duration
+		ss    []Setting
+	}{
+		{"fast", "SELECT * FROM t", 10 * time.Millisecond, nil},
+		{"write", "UPDATE t SET a = 1", time.Second, nil},
+		{"sensitive", "SELECT * FROM users", time.Second, []Setting{WithSensitiveTableList("users")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log testLog
+			dri := newTimedDriver(dialect.Postgres, tt.took)
+			ss := append([]Setting{WithLogger(log.Logger()), WithClock(dri.clock.Now),
+				WithSlowThreshold(100 * time.Millisecond), WithSlowQueryExplain(time.Minute)}, tt.ss...)
+			drv := New(dri, ss...)
+			var rows sql.Rows
+			if err := drv.Query(context.Background(), tt.query, []any{}, &rows); err != nil {
+				t.Fatal(err)
+			}
+			rows.Close()
+			// Plans are captured in the background, give a capture the time to run.
+			time.Sleep(20 * time.Millisecond)
+			if records := log.Records(t, "Query plan"); len(records) != 0 {
+				t.Errorf("got plan records %v, want none", records)
+			}
+		})
+	}
+}
+
+func TestAnalyzable(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT * FROM t", true},
+		{"SELECT * FROM t FOR UPDATE", false},
+		{"SELECT * FROM t LOCK IN SHARE MODE", false},
+		{"SELECT * INTO u FROM t", false},
+		{"WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d", false},
+		{"SELECT 1; SELECT 2", false},
+		{"SELECT 1;", true},
+		{"UPDATE t SET a = 1", false},
+	}
+	for _, tt := range tests {
+		tokens := significant(scanSQL(tt.query))
+		if got := analyzable(classOf(tokens), tokens); got != tt.want {
+			t.Errorf("analyzable(%q) = %t, want %t", tt.query, got, tt.want)
+		}
+	}
+}
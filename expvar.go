@@ -0,0 +1,49 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"expvar"
+)
+
+// expvarCounters is an Observer publishing per-operation counters through expvar.
+type expvarCounters struct {
+	m *expvar.Map
+}
+
+// newExpvarCounters publishes a map under name. Drivers configured with the same name
+// share the same counters instead of panicking on a duplicate publication.
+func newExpvarCounters(name string) *expvarCounters {
+	m, ok := expvar.Get(name).(*expvar.Map)
+	if !ok {
+		m = expvar.NewMap(name)
+	}
+	return &expvarCounters{m: m}
+}
+
+// Observe implements Observer.
+func (c *expvarCounters) Observe(_ context.Context, e Event) {
+	c.m.Add(counterName(e.Op), 1)
+	if e.Err != nil {
+		c.m.Add("errors", 1)
+	}
+}
+
+// counterName maps an operation name to its counter, merging the context variants.
+func counterName(op string) string {
+	switch op {
+	case "Exec", "ExecContext":
+		return "exec"
+	case "Query", "QueryContext":
+		return "query"
+	case "Tx", "BeginTx":
+		return "tx"
+	case "Commit":
+		return "commit"
+	case "Rollback":
+		return "rollback"
+	}
+	return op
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// namedFilter is an attribute filter registered with Handler.AddFilter.
+type namedFilter struct {
+	name   string
+	filter FilterAttrs
+}
+
+// filterSet holds the attribute filters registered at runtime, shared by the handlers
+// of a driver and its transactions. Records read the filters without locking.
+type filterSet struct {
+	mu      sync.Mutex // serializes the changes.
+	filters atomic.Pointer[[]namedFilter]
+}
+
+func newFilterSet() *filterSet {
+	return new(filterSet)
+}
+
+// load returns the registered filters, in registration order.
+func (s *filterSet) load() []namedFilter {
+	if filters := s.filters.Load(); filters != nil {
+		return *filters
+	}
+	return nil
+}
+
+func (s *filterSet) add(name string, filter FilterAttrs) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	filters := slices.Clone(s.load())
+	if i := slices.IndexFunc(filters, func(f namedFilter) bool { return f.name == name }); i >= 0 {
+		filters[i].filter = filter
+	} else {
+		filters = append(filters, namedFilter{name: name, filter: filter})
+	}
+	s.filters.Store(&filters)
+}
+
+func (s *filterSet) remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	filters := s.load()
+	i := slices.IndexFunc(filters, func(f namedFilter) bool { return f.name == name })
+	if i < 0 {
+		return false
+	}
+	filters = slices.Delete(slices.Clone(filters), i, i+1)
+	s.filters.Store(&filters)
+	return true
+}
+
+// apply passes attrs through the registered filters, in order.
+func (s *filterSet) apply(ctx context.Context, attrs []slog.Attr) []slog.Attr {
+	for _, f := range s.load() {
+		attrs = f.filter(ctx, attrs...)
+	}
+	return attrs
+}
+
+// AddFilter registers filter under name, so redaction can be toggled at runtime, e.g. by
+// a feature flag. The registered filters are applied in registration order to the
+// attributes returned by the filter of WithFilter, and affect the subsequent records of
+// the driver and of its transactions, including those already started. A filter already
+// registered under name is replaced in place. It is safe for concurrent use.
+//
+// - `name`: The name identifying the filter for RemoveFilter.
+// - `filter`: The filter, nil is ignored.
+func (h *Handler) AddFilter(name string, filter FilterAttrs) {
+	if filter == nil {
+		return
+	}
+	h.filters.add(name, filter)
+}
+
+// RemoveFilter unregisters the filter registered under name by AddFilter and reports
+// whether there was one. It is safe for concurrent use.
+func (h *Handler) RemoveFilter(name string) bool {
+	return h.filters.remove(name)
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"container/list"
+	"log/slog"
+	"sync"
+)
+
+// fingerprintLRU remembers the most recently executed fingerprints.
+type fingerprintLRU struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List               // most recently used first.
+	items map[string]*list.Element // fingerprint -> element of order.
+}
+
+func newFingerprintLRU(size int) *fingerprintLRU {
+	return &fingerprintLRU{size: size, order: list.New(), items: make(map[string]*list.Element, size)}
+}
+
+// add records fp and reports whether it was not already known.
+func (c *fingerprintLRU) add(fp string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[fp]; ok {
+		c.order.MoveToFront(e)
+		return false
+	}
+	c.items[fp] = c.order.PushFront(fp)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(string))
+	}
+	return true
+}
+
+// logFirstSeen logs the statement when its fingerprint executes for the first time.
+func (op *operation) logFirstSeen() {
+	if op.firstSeen == nil || op.query == "" {
+		return
+	}
+	fp := op.fingerprint()
+	if !op.firstSeen.add(fp) {
+		return
+	}
+	attrs := append(op.queryAttrs(op.sensitive), op.subjectAttrs()...)
+	op.logAt(op.ctx, slog.LevelInfo, "new query fingerprint", attrs...)
+}
@@ -6,33 +6,105 @@ package entslog
 import (
 	"context"
 	"log/slog"
+	"regexp"
 	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Handler struct {
-	logger *slog.Logger
-	filter FilterAttrs
-	trace  TraceFunc
-	log    func(ctx context.Context, msg string, attrs ...slog.Attr)
-	error  func(ctx context.Context, msg string, err error) error
-	attrs  []slog.Attr
-}
+	logger      *slog.Logger
+	filter      FilterAttrs
+	trace       TraceFunc
+	level       slog.Leveler
+	errorLevel  slog.Leveler
+	handleError bool
+	connSource  bool         // report whether operations ran on pooled or freshly dialed connections.
+	tracer      trace.Tracer // creates a span per operation, nil when tracing is disabled.
+	dialect     string       // dialect name of the underlying driver.
 
-func (h *Handler) init(o *Option) *Handler {
-	h.log = func(ctx context.Context, msg string, attrs ...slog.Attr) {
-		attrs = h.Filter(ctx, attrs...)
-		h.logger.LogAttrs(ctx, o.level.Level(), msg, attrs...)
-	}
-	if o.handleError {
-		h.error = func(ctx context.Context, msg string, err error) error {
-			if err != nil {
-				attrs := h.Filter(ctx, slog.Any("error", err))
-				h.logger.LogAttrs(ctx, o.errorLevel.Level(), msg, attrs...)
-			}
-			return err
-		}
-	}
-	return h
+	sensitiveTables map[string]bool  // tables whose statements are always redacted.
+	sensitiveCols   map[string]bool  // columns whose bound arguments are always redacted.
+	slos            *sloSet          // latency objectives, nil when none are configured.
+	sqlCommenter    bool             // append a sqlcommenter comment to outgoing queries.
+	application     string           // application name reported in sqlcommenter comments.
+	slowThreshold   time.Duration    // statements taking longer are logged as slow, zero disables.
+	observers       []Observer       // receive an Event for every completed operation.
+	mutationDiff    MutationDiffFunc // supplies field diffs of UPDATE statements in transactions.
+	profile         SchemaProfile    // attribute naming convention of the emitted records.
+	stats           *statsRecorder   // cumulative operation statistics.
+	report          *reportRecorder  // statistics of the current report period, nil unless WithReportWriter is set.
+	ctxAudit        *contextAudit    // reports operations without request context, nil when disabled.
+	pprofLabels     bool             // label the goroutine with the operation while it runs.
+	datadogIDs      TraceIDsFunc     // extracts Datadog correlation ids, nil when disabled.
+	resultSchemas   *sync.Map        // fingerprints whose result set schema was logged, nil when disabled.
+	fingerprints    bool             // add the query fingerprint to statement records.
+	queryHash       bool             // add the hash of the query fingerprint to statement records.
+	normalizeQuery  bool             // collapse the whitespace of logged queries.
+	pretty          bool             // reindent logged queries for development.
+	prettyColor     bool             // colorize reindented queries with ANSI escape sequences.
+	interpolate     bool             // log statements with their arguments substituted, for debugging.
+	maxQueryLength  int              // logged queries longer than this are truncated, zero disables.
+	execResult      bool             // report the rows affected and last insert id of Exec results.
+	eventIDs        bool             // add an ordering event id to every record and Event.
+	rowCounts       bool             // log the size of result sets when they are closed.
+	firstSeen       *fingerprintLRU  // recently executed fingerprints, nil unless WithFirstSeenQueries is set.
+	leakCheck       bool             // report result sets that are not closed.
+	leakAfter       time.Duration    // result sets open longer are reported, zero only reports garbage collected ones.
+	nPlusOne        int              // executions of a fingerprint per request or transaction above which a warning is logged.
+	txCounts        *queryCounter    // executions by fingerprint of the transaction, nil outside transactions.
+	queryBudget     int              // statements per counted context above which a warning is logged.
+	explain         *explainer       // captures the plans of slow statements, nil unless WithSlowQueryExplain is set.
+	analyze         *explainer       // analyzes slow statements, nil unless WithExplainAnalyze is set in the Development preset.
+	inflight        *inflightOps     // running operations notices are attributed to, nil unless WithNoticeAttribution is set.
+	splitLogging    bool             // log each statement of multi-statement queries in its own record.
+	originHash      bool             // add the hash of the fingerprint and calling package to statement records.
+	omitArgs        bool             // never log the arguments of statements.
+	volume          *volumeTracker   // detects spikes of the execution rate, nil unless WithVolumeSpikeDetection is set.
+	argFilter       ArgFilterFunc    // returns the logged value of each bind argument, nil logs them unchanged.
+	classifier      Classifier       // labels the sensitive bind arguments, which are masked, nil disables classification.
+	queryFilter     QueryFilterFunc  // returns the logged text of each query, nil logs it unchanged.
+	maxArgSize      int              // byte slices and strings longer than this are summarized, zero disables.
+	maxArgsSize     int              // formatted size above which the args attribute is truncated, zero disables.
+	argSalt         []byte           // key of the salted hashes replacing the arguments, nil logs their values.
+	sanitizer       Sanitizer        // masks the personal data of the arguments, nil unless WithSanitizers is set.
+	chain           *recordChain     // links the records of the driver by hashes, nil unless WithRecordChain is set.
+	static          []slog.Attr      // attributes of every record, set by WithAttrs.
+	group           string           // group nesting the attributes of every record, empty keeps them at the top level.
+	opLevels        levelMap         // default levels by operation name, overriding level.
+	errLevelFunc    ErrorLevelFunc   // computes the level of error records, nil uses errorLevel.
+	benign          []BenignFunc     // match the errors logged at Debug level, such as missing rows.
+	errFilter       ErrorFilterFunc  // reports whether an error is logged, nil logs every error.
+	noTxLogs        bool             // suppress the records of transaction starts, commits and rollbacks.
+	ops             *opSet           // operations whose records are logged, nil logs every operation.
+	muted           bool             // suppress the records of the operation below the error and warning levels.
+	ignored         []*regexp.Regexp // patterns of the queries whose records are not logged.
+	messageFunc     MessageFunc      // returns the logged message of every record, nil keeps it.
+	msgPrefix       string           // prefix of the message of every record.
+	clock           func() time.Time // returns the current time, nil uses time.Now.
+	ctxAttrs        ContextAttrsFunc // returns the attributes derived from the context, nil unless WithContextAttrs is set.
+	filters         *filterSet       // attribute filters registered at runtime by AddFilter.
+	ctxTrace        bool             // add the trace id of the context to every record.
+	ddlLevel        slog.Leveler     // default level of DDL statements, nil uses opLevels and level.
+	bridgeMethods   bool             // bridge the context methods missing from the driver and its transactions.
+	scanErrors      bool             // log scan errors with the details of the column.
+	readOnlyCheck   bool             // detect writes succeeding in read-only transactions.
+	readOnly        *readOnlyTx      // set in transactions begun read-only with readOnlyCheck, nil otherwise.
+	audit           *auditChain      // hash-chained mutation audit trail, nil unless WithAuditSink is set.
+	audited         *atomic.Bool     // whether the transaction recorded mutations in the audit trail, nil outside transactions.
+	shedder         *txShedder       // caps the concurrently logged transactions, nil unless WithMaxConcurrentLoggedTx is set.
+	txSummary       *txSummary       // summary of a transaction whose statements are not logged, nil otherwise.
+	levelFunc       LevelFunc        // computes the level of outcome records, nil uses the fixed levels.
+	sessionTags     bool             // store the request id in a session variable at transaction start.
+	sessionTag      SessionTagFunc   // returns the request id, nil uses the transaction id.
+	cancelGrace     time.Duration    // operations outliving their context cancellation by more are reported, zero disables.
+	history         *queryHistory    // last statements, nil unless WithQueryHistory is set.
+	txID            string           // transaction logging id, empty outside transactions.
+	sink            *asyncSink       // asynchronous record queue, nil when logging synchronously.
+	attrs           []slog.Attr
 }
 
 func (h *Handler) with(attrs ...slog.Attr) Handler {
@@ -46,47 +118,258 @@ func (h *Handler) WithTrace(ctx context.Context) string {
 }
 
 func (h *Handler) Filter(ctx context.Context, attrs ...slog.Attr) []slog.Attr {
-	return h.filter(ctx, slices.Concat(h.attrs, attrs)...)
+	return h.filters.apply(ctx, h.filter(ctx, slices.Concat(h.static, h.attrs, h.contextAttrs(ctx), attrs)...))
+}
+
+// contextAttrs returns the attributes derived from the context of a record.
+func (h *Handler) contextAttrs(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+	if h.ctxTrace {
+		attrs = append(attrs, h.traceAttr(ctx))
+	}
+	if id := RequestIDFromContext(ctx); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if h.datadogIDs != nil {
+		attrs = append(attrs, h.datadogAttrs(ctx)...)
+	}
+	if h.ctxAttrs != nil {
+		attrs = append(attrs, h.ctxAttrs(ctx)...)
+	}
+	return attrs
 }
 
 func (h *Handler) Log(ctx context.Context, msg string, attrs ...slog.Attr) {
-	h.log(ctx, msg, attrs...)
+	if h.txSummary != nil || h.muted {
+		// The statements of shed transactions are only summarized, excluded operations are not logged.
+		return
+	}
+	h.logAt(ctx, h.level.Level(), msg, attrs...)
 }
 
-func (h *Handler) LogError(ctx context.Context, msg string, err error) error {
-	return h.error(ctx, msg, err)
+// logAt logs a record at the given level, regardless of the default level.
+func (h *Handler) logAt(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	h.write(ctx, level, msg, h.Filter(ctx, attrs...)...)
 }
 
-func errorLog(ctx context.Context, msg string, err error) error {
+func (h *Handler) LogError(ctx context.Context, msg string, err error, attrs ...slog.Attr) error {
+	if err != nil && h.handleError && (h.errFilter == nil || h.errFilter(ctx, err)) {
+		attrs = append([]slog.Attr{slog.Any("error", err)}, attrs...)
+		h.logAt(ctx, h.errorLevelOf(err), msg, attrs...)
+	}
 	return err
 }
 
-func makeHandle(o *Option) *Handler {
-	// Define a filter function to modify log attributes based on the FilterAttrs option.
+// now returns the current time of the clock set by WithClock.
+func (h *Handler) now() time.Time {
+	if h.clock != nil {
+		return h.clock()
+	}
+	return time.Now()
+}
+
+// errorLevelOf returns the level of the record logging err.
+func (h *Handler) errorLevelOf(err error) slog.Level {
+	if h.errLevelFunc != nil {
+		if level := h.errLevelFunc(err); level != nil {
+			return level.Level()
+		}
+	}
+	if h.isBenign(err) {
+		return slog.LevelDebug
+	}
+	return h.errorLevel.Level()
+}
+
+// write hands a fully assembled record to the logger, either directly or via the async queue.
+func (h *Handler) write(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if h.eventIDs {
+		attrs = append([]slog.Attr{slog.String("event_id", nextEventID())}, attrs...)
+	}
+	attrs = h.profile.apply(attrs)
+	if h.messageFunc != nil {
+		msg = h.messageFunc(msg)
+	}
+	msg = h.msgPrefix + msg
+	if h.group != "" {
+		attrs = []slog.Attr{{Key: h.group, Value: slog.GroupValue(attrs...)}}
+	}
+	if h.chain != nil {
+		// Records dropped by the logger must not take a link of the chain.
+		if !h.logger.Enabled(ctx, level) {
+			return
+		}
+		attrs = h.chain.link(ctx, level, msg, attrs)
+	}
+	if h.sink != nil {
+		h.sink.log(ctx, level, msg, attrs)
+		return
+	}
+	h.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func makeHandle(dialect string, o *Option) *Handler {
 	if o.logger == nil {
 		o.logger = slog.Default()
 	}
 
 	h := Handler{
-		logger: o.logger,
-		filter: o.filter,
-		trace:  o.trace,
-		error:  errorLog,
-	}
-	h.log = func(ctx context.Context, msg string, attrs ...slog.Attr) {
-		attrs = h.Filter(ctx, attrs...)
-		h.logger.LogAttrs(ctx, o.level.Level(), msg, attrs...)
-	}
-	if o.handleError {
-		h.error = func(ctx context.Context, msg string, err error) error {
-			if err != nil {
-				attrs := h.Filter(ctx, slog.Any("error", err))
-				h.logger.LogAttrs(ctx, o.errorLevel.Level(), msg, attrs...)
-			}
-			return err
-		}
+		logger:      o.logger,
+		filter:      o.filter,
+		trace:       o.trace,
+		level:       o.level,
+		errorLevel:  o.errorLevel,
+		handleError: o.handleError,
+		connSource:  o.connSource,
+		tracer:      o.tracer,
+
+		sensitiveTables: o.sensitiveTables,
+		sensitiveCols:   o.sensitiveCols,
+		slos:            o.slos,
+		sqlCommenter:    o.sqlCommenter,
+		application:     o.application,
+		slowThreshold:   o.slowThreshold,
+		observers:       o.observers,
+		mutationDiff:    o.mutationDiff,
+		profile:         o.profile,
+		dialect:         dialect,
+		stats:           newStatsRecorder(o.statsStore != nil),
+		pprofLabels:     o.pprofLabels,
+		datadogIDs:      o.datadogIDs,
+		fingerprints:    o.fingerprints,
+		queryHash:       o.queryHash,
+		normalizeQuery:  o.normalizeQuery,
+		pretty:          o.pretty,
+		prettyColor:     o.prettyColor,
+		interpolate:     o.interpolate,
+		maxQueryLength:  o.maxQueryLength,
+		execResult:      o.execResult,
+		eventIDs:        o.eventIDs,
+		rowCounts:       o.rowCounts,
+		leakCheck:       o.leakCheck,
+		leakAfter:       o.leakAfter,
+		nPlusOne:        o.nPlusOne,
+		queryBudget:     o.queryBudget,
+		splitLogging:    o.splitLogging,
+		originHash:      o.originHash,
+		omitArgs:        o.omitArgs,
+		argFilter:       o.argFilter,
+		classifier:      o.classifier,
+		queryFilter:     o.queryFilter,
+		maxArgSize:      o.maxArgSize,
+		maxArgsSize:     o.maxArgsSize,
+		argSalt:         o.argSalt,
+		static:          o.attrs,
+		group:           o.group,
+		opLevels:        o.opLevels,
+		errLevelFunc:    o.errLevelFunc,
+		benign:          o.benign,
+		errFilter:       o.errFilter,
+		noTxLogs:        o.noTxLogs,
+		ops:             o.ops,
+		ignored:         o.ignored,
+		messageFunc:     o.messageFunc,
+		msgPrefix:       o.msgPrefix,
+		clock:           o.clock,
+		ctxAttrs:        o.ctxAttrs,
+		filters:         newFilterSet(),
+		ctxTrace:        o.ctxTrace,
+		ddlLevel:        o.ddlLevel,
+		bridgeMethods:   o.bridgeMethods,
+		scanErrors:      o.scanErrors,
+		readOnlyCheck:   o.readOnlyCheck,
+		levelFunc:       o.levelFunc,
+		sessionTags:     o.sessionTags,
+		sessionTag:      o.sessionTag,
+		cancelGrace:     o.cancelGrace,
+	}
+	if o.resultSchema {
+		h.resultSchemas = new(sync.Map)
+	}
+	if o.firstSeen > 0 {
+		h.firstSeen = newFingerprintLRU(o.firstSeen)
+	}
+	if o.contextAudit {
+		h.ctxAudit = new(contextAudit)
+	}
+	switch {
+	case o.profile == SchemaOTel:
+		system := dbSystem(dialect)
+		h.static = append([]slog.Attr{slog.String(string(system.Key), system.Value.AsString())}, h.static...)
+	case !o.noDialect:
+		h.static = append([]slog.Attr{slog.String("dialect", dialect)}, h.static...)
+	}
+	if len(o.sanitizers) > 0 {
+		h.sanitizer = sanitizerChain(o.sanitizers)
+	}
+	if o.notices {
+		h.inflight = newInflightOps()
+	}
+	if o.auditSink != nil {
+		h.audit = &auditChain{w: o.auditSink}
+	}
+	if o.maxLoggedTx > 0 {
+		h.shedder = &txShedder{max: int64(o.maxLoggedTx)}
+	}
+	if o.volumeFactor > 0 {
+		h.volume = newVolumeTracker(o.volumeFactor, o.volumeWindow)
+	}
+	if o.historySize > 0 {
+		h.history = newQueryHistory(o.historySize, o.queryStore)
+	}
+	h.observers = append(slices.Clip(h.observers), h.stats)
+	if o.meter != nil {
+		h.observers = append(slices.Clip(h.observers), newOTelMetrics(o.meter, dialect))
+	}
+	if o.reportWriter != nil && o.reportInterval > 0 {
+		h.report = newReportRecorder()
+		h.observers = append(slices.Clip(h.observers), h.report)
+	}
+	if o.expvarName != "" {
+		h.observers = append(slices.Clip(h.observers), newExpvarCounters(o.expvarName))
+	}
+	if o.async > 0 {
+		h.sink = newAsyncSink(o.logger, o.async, o.shutdownTimeout)
 	}
 
 	// Return a configured logging handler.
 	return &h
 }
+
+// inherit takes over the state of prev, the handler h replaces: the statistics, the
+// asynchronous queue and the components tied to the lifecycle of the driver are kept,
+// and the trackers enabled in both handlers keep their history.
+func (h *Handler) inherit(prev *Handler) {
+	for i, o := range h.observers {
+		switch o {
+		case h.stats:
+			h.observers[i] = prev.stats
+		case h.report:
+			h.observers[i] = prev.report
+		}
+	}
+	h.stats, h.report, h.sink = prev.stats, prev.report, prev.sink
+	h.chain, h.explain, h.analyze = prev.chain, prev.explain, prev.analyze
+	h.audit, h.shedder, h.filters = prev.audit, prev.shedder, prev.filters
+	if h.slos != nil && prev.slos != nil {
+		h.slos.inherit(prev.slos)
+	}
+	if h.firstSeen != nil && prev.firstSeen != nil {
+		h.firstSeen = prev.firstSeen
+	}
+	if h.resultSchemas != nil && prev.resultSchemas != nil {
+		h.resultSchemas = prev.resultSchemas
+	}
+	if h.ctxAudit != nil && prev.ctxAudit != nil {
+		h.ctxAudit = prev.ctxAudit
+	}
+	if h.inflight != nil && prev.inflight != nil {
+		h.inflight = prev.inflight
+	}
+	if h.volume != nil && prev.volume != nil {
+		h.volume = prev.volume
+	}
+	if h.history != nil && prev.history != nil {
+		h.history = prev.history
+	}
+}
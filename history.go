@@ -0,0 +1,188 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// HistoryEntry is a statement of the query history kept by WithQueryHistory.
+	HistoryEntry struct {
+		Time        time.Time     // Time is the start time of the statement.
+		Op          string        // Op is the driver method, such as Exec or Query.
+		Query       string        // Query is the statement, with its literals scrubbed when it touches a sensitive table.
+		Fingerprint string        // Fingerprint is the fingerprint of the statement.
+		Duration    time.Duration // Duration is the execution time of the statement.
+		TxID        string        // TxID is the transaction id, empty outside transactions.
+		RequestID   string        // RequestID is the request id of the context, if any.
+		Err         string        // Err is the error returned by the statement, empty on success.
+	}
+	// QueryStore holds the query text of the entries of the query history, so that the
+	// entries of a statement executed many times share a single copy of its text, which
+	// may be compressed. It must be safe for concurrent use.
+	QueryStore interface {
+		// Acquire stores query, whose fingerprint is fingerprint, or adds a reference to
+		// the stored copy of the same text, and returns its id.
+		Acquire(fingerprint, query string) uint64
+		// Load returns the fingerprint and the text of the query stored with id.
+		Load(id uint64) (fingerprint, query string)
+		// Release drops a reference to the query stored with id, which is removed from the
+		// store with its last reference.
+		Release(id uint64)
+	}
+)
+
+// storedQuery is a query text of the store and the number of history entries holding it.
+type storedQuery struct {
+	id          uint64
+	fingerprint string
+	query       string
+	refs        int
+}
+
+// queryStore is the QueryStore deduplicating the query texts by fingerprint.
+type queryStore struct {
+	mu            sync.Mutex
+	last          uint64
+	byID          map[uint64]*storedQuery
+	byFingerprint map[string][]*storedQuery // texts by fingerprint, usually a single one.
+}
+
+// NewQueryStore returns a QueryStore keeping a single copy of each distinct query text,
+// found by its fingerprint and reference counted, so that the history of a statement
+// executed thousands of times holds its text once.
+func NewQueryStore() QueryStore {
+	return &queryStore{byID: make(map[uint64]*storedQuery), byFingerprint: make(map[string][]*storedQuery)}
+}
+
+func (s *queryStore) Acquire(fingerprint, query string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, q := range s.byFingerprint[fingerprint] {
+		if q.query == query {
+			q.refs++
+			return q.id
+		}
+	}
+	s.last++
+	q := &storedQuery{id: s.last, fingerprint: fingerprint, query: query, refs: 1}
+	s.byID[q.id] = q
+	s.byFingerprint[fingerprint] = append(s.byFingerprint[fingerprint], q)
+	return q.id
+}
+
+func (s *queryStore) Load(id uint64) (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if q, ok := s.byID[id]; ok {
+		return q.fingerprint, q.query
+	}
+	return "", ""
+}
+
+func (s *queryStore) Release(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q, ok := s.byID[id]
+	if !ok {
+		return
+	}
+	if q.refs--; q.refs > 0 {
+		return
+	}
+	delete(s.byID, id)
+	texts := s.byFingerprint[q.fingerprint]
+	for i, other := range texts {
+		if other == q {
+			texts = append(texts[:i], texts[i+1:]...)
+			break
+		}
+	}
+	if len(texts) == 0 {
+		delete(s.byFingerprint, q.fingerprint)
+	} else {
+		s.byFingerprint[q.fingerprint] = texts
+	}
+}
+
+// queryHistory is a ring buffer of the last statements, whose query texts are held by a
+// QueryStore. The entries keep the id of their text in place of the text and fingerprint.
+type queryHistory struct {
+	mu      sync.Mutex
+	store   QueryStore
+	entries []historyEntry
+	next    int  // index of the next entry to write.
+	full    bool // whether every entry was written.
+}
+
+// historyEntry is a HistoryEntry whose query text and fingerprint are in the store.
+type historyEntry struct {
+	HistoryEntry
+	query uint64
+}
+
+func newQueryHistory(size int, store QueryStore) *queryHistory {
+	if store == nil {
+		store = NewQueryStore()
+	}
+	return &queryHistory{store: store, entries: make([]historyEntry, size)}
+}
+
+// add appends e to the history, evicting the oldest entry when it is full.
+func (q *queryHistory) add(e HistoryEntry) {
+	id := q.store.Acquire(e.Fingerprint, e.Query)
+	e.Query, e.Fingerprint = "", ""
+	q.mu.Lock()
+	evicted := q.entries[q.next]
+	q.entries[q.next] = historyEntry{HistoryEntry: e, query: id}
+	q.next = (q.next + 1) % len(q.entries)
+	full := q.full
+	q.full = q.full || q.next == 0
+	q.mu.Unlock()
+	if full {
+		q.store.Release(evicted.query)
+	}
+}
+
+// snapshot returns the entries of the history, oldest first.
+func (q *queryHistory) snapshot() []HistoryEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entries := make([]HistoryEntry, 0, len(q.entries))
+	start, n := 0, q.next
+	if q.full {
+		start, n = q.next, len(q.entries)
+	}
+	for i := 0; i < n; i++ {
+		e := q.entries[(start+i)%len(q.entries)]
+		e.Fingerprint, e.Query = q.store.Load(e.query)
+		entries = append(entries, e.HistoryEntry)
+	}
+	return entries
+}
+
+// recordHistory appends the finished statement to the query history.
+func (op *operation) recordHistory(err error) {
+	if op.history == nil || op.query == "" {
+		return
+	}
+	e := HistoryEntry{
+		Time:        op.start,
+		Op:          op.name,
+		Query:       op.query,
+		Fingerprint: op.fingerprint(),
+		Duration:    op.took,
+		TxID:        op.txID,
+		RequestID:   RequestIDFromContext(op.ctx),
+	}
+	if op.sensitive {
+		e.Query = scrubLiterals(e.Query)
+	}
+	if err != nil {
+		e.Err = err.Error()
+	}
+	op.history.add(e)
+}
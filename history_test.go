@@ -0,0 +1,105 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryHistory(t *testing.T) {
+	var log testLog
+	store := NewQueryStore().(*queryStore)
+	fake := &fakeDriver{clock: newTestClock(), took: time.Millisecond}
+	drv := newTestDriver(fake, &log, WithQueryHistory(3, store), WithSensitiveTableList("secrets")).(*SlogDriver)
+	ctx, id := EnsureRequestID(context.Background())
+	queries := []string{
+		"UPDATE t SET a = ?",
+		"UPDATE t SET a = ?",
+		"DELETE FROM t WHERE a = ?",
+		"UPDATE t SET a = ?",
+		"UPDATE secrets SET token = 'abc' WHERE id = ?",
+	}
+	for _, query := range queries {
+		if err := drv.Exec(ctx, query, []any{1}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake.err = errors.New("deadlock")
+	_ = drv.Exec(ctx, "DELETE FROM t WHERE a = ?", []any{1}, nil)
+
+	history := drv.History()
+	if len(history) != 3 {
+		t.Fatalf("got %d history entries, want 3", len(history))
+	}
+	want := []string{"UPDATE t SET a = ?", scrubLiterals(queries[4]), "DELETE FROM t WHERE a = ?"}
+	for i, e := range history {
+		if e.Query != want[i] || e.Fingerprint != fingerprint(want[i]) {
+			t.Errorf("entry %d = %q, %q, want %q", i, e.Query, e.Fingerprint, want[i])
+		}
+		if e.Op != "Exec" || e.RequestID != id || e.Duration != time.Millisecond {
+			t.Errorf("entry %d = %+v", i, e)
+		}
+		if i > 0 && !e.Time.After(history[i-1].Time) {
+			t.Errorf("entry %d at %v, not after %v", i, e.Time, history[i-1].Time)
+		}
+	}
+	if strings.Contains(history[1].Query, "abc") {
+		t.Errorf("sensitive entry = %q", history[1].Query)
+	}
+	if history[2].Err != "deadlock" || history[0].Err != "" {
+		t.Errorf("errors = %q, %q", history[0].Err, history[2].Err)
+	}
+	// The evicted statements released their texts, the others share them.
+	if len(store.byID) != 3 {
+		t.Errorf("store holds %d texts, want 3", len(store.byID))
+	}
+	if drv := newTestDriver(fake, &log).(*SlogDriver); drv.History() != nil {
+		t.Errorf("History without WithQueryHistory = %v", drv.History())
+	}
+}
+
+func TestQueryStore(t *testing.T) {
+	store := NewQueryStore().(*queryStore)
+	const size = 10000
+	history := newQueryHistory(size, store)
+	query := "SELECT " + strings.Repeat("a, ", 1000) + "b FROM t WHERE id = ?"
+	fp := fingerprint(query)
+	for i := 0; i < 2*size; i++ {
+		history.add(HistoryEntry{Query: query, Fingerprint: fp})
+	}
+	if len(store.byID) != 1 || store.byID[1].refs != size {
+		t.Fatalf("store = %d texts, want the single text referenced %d times", len(store.byID), size)
+	}
+	// Texts sharing a fingerprint are kept apart.
+	other := strings.Replace(query, "WHERE id = ?", "WHERE id = 1", 1)
+	if fingerprint(other) != fp {
+		t.Fatalf("fingerprint(%q) != fingerprint(%q)", other[len(other)-20:], query[len(query)-20:])
+	}
+	for i := 0; i < size; i++ {
+		history.add(HistoryEntry{Query: other, Fingerprint: fp})
+	}
+	if len(store.byID) != 1 || len(store.byFingerprint[fp]) != 1 {
+		t.Fatalf("store = %d texts, want the other text only", len(store.byID))
+	}
+	for _, e := range history.snapshot() {
+		if e.Query != other {
+			t.Fatalf("entry = %q", e.Query[len(e.Query)-20:])
+		}
+	}
+	history.add(HistoryEntry{Query: query, Fingerprint: fp})
+	if got := len(store.byFingerprint[fp]); got != 2 {
+		t.Errorf("store holds %d texts for the fingerprint, want 2", got)
+	}
+	for i := 0; i < size; i++ {
+		history.add(HistoryEntry{Query: fmt.Sprint("SELECT ", i), Fingerprint: fmt.Sprint(i)})
+	}
+	if len(store.byID) != size || len(store.byFingerprint[fp]) != 0 {
+		t.Errorf("store = %d texts, %d for the evicted fingerprint", len(store.byID), len(store.byFingerprint[fp]))
+	}
+}
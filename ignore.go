@@ -0,0 +1,43 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"regexp"
+	"strings"
+)
+
+// globPattern returns the case-insensitive regular expression matching the whole queries
+// matched by glob, where * matches any text and ? any single character.
+func globPattern(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString(`(?is)^`)
+	for _, part := range strings.SplitAfter(collapseSpace(glob), "") {
+		switch part {
+		case "*":
+			b.WriteString(`.*`)
+		case "?":
+			b.WriteString(`.`)
+		default:
+			b.WriteString(regexp.QuoteMeta(part))
+		}
+	}
+	b.WriteString(`$`)
+	return regexp.MustCompile(b.String())
+}
+
+// ignoresQuery reports whether query matches one of the ignored query patterns, once its
+// whitespace is collapsed.
+func (h *Handler) ignoresQuery(query string) bool {
+	if len(h.ignored) == 0 || query == "" {
+		return false
+	}
+	query = collapseSpace(query)
+	for _, re := range h.ignored {
+		if re.MatchString(query) {
+			return true
+		}
+	}
+	return false
+}
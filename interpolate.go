@@ -0,0 +1,108 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"entgo.io/ent/dialect"
+)
+
+// logInterpolated logs the statement with its arguments substituted into the placeholders,
+// at debug level. Statements touching sensitive tables are never interpolated.
+func (op *operation) logInterpolated(args any) {
+	values, ok := op.filterArgs(args).([]any)
+	if !ok || op.sensitive {
+		return
+	}
+	query := interpolate(op.query, values, op.dialect)
+	if op.queryFilter != nil {
+		query = op.queryFilter(op.ctx, query)
+	}
+	op.logAt(op.ctx, slog.LevelDebug, op.name+" interpolated", append(op.labelAttrs(),
+		slog.String("query", query),
+		slog.Bool("debug_only", true),
+	)...)
+}
+
+// interpolate substitutes args into the ? and $N placeholders of query. The result is
+// meant to be copied into a SQL console and is never sent to the database.
+func interpolate(query string, args []any, name string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	next := 0
+	for _, t := range scanSQL(query) {
+		if t.kind != tokParam {
+			b.WriteString(t.text)
+			continue
+		}
+		i := -1
+		switch {
+		case t.text == "?":
+			i, next = next, next+1
+		case t.text[0] == '$':
+			if n, err := strconv.Atoi(t.text[1:]); err == nil {
+				i = n - 1
+			}
+		}
+		if i < 0 || i >= len(args) {
+			b.WriteString(t.text)
+			continue
+		}
+		b.WriteString(sqlLiteral(args[i], name))
+	}
+	return b.String()
+}
+
+// sqlLiteral formats v as a SQL literal of the dialect.
+func sqlLiteral(v any, name string) string {
+	if valuer, ok := v.(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return "?"
+		}
+		v = value
+	}
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return quoteLiteral(v)
+	case []byte:
+		if name == dialect.Postgres {
+			return `'\x` + hex.EncodeToString(v) + "'"
+		}
+		return "X'" + hex.EncodeToString(v) + "'"
+	case bool:
+		if name == dialect.SQLite {
+			if v {
+				return "1"
+			}
+			return "0"
+		}
+		return strings.ToUpper(strconv.FormatBool(v))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return quoteLiteral(v.Format("2006-01-02 15:04:05.999999Z07:00"))
+	case fmt.Stringer:
+		return quoteLiteral(v.String())
+	}
+	return quoteLiteral(fmt.Sprint(v))
+}
+
+// quoteLiteral quotes s as a SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
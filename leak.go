@@ -0,0 +1,70 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// rowsLeak reports the result sets that are not closed. It does not reference the
+// result set, so that a pending timer does not keep a leaked result set reachable.
+type rowsLeak struct {
+	h      *Handler
+	ctx    context.Context
+	name   string
+	query  string
+	labels []slog.Attr // labels of the query.
+	caller string      // call site of the query, empty when unknown.
+	timer  *time.Timer // fires when the result set stays open too long, nil when disabled.
+	once   sync.Once   // reports a leak at most once.
+}
+
+// watchLeak starts watching r for leaks.
+func (op *operation) watchLeak(r *loggedRows) {
+	l := &rowsLeak{h: op.Handler, ctx: op.ctx, name: op.name, query: op.query, labels: op.labelAttrs()}
+	if frame, ok := callerFrame(); ok {
+		l.caller = frame.File + ":" + strconv.Itoa(frame.Line)
+	}
+	if op.leakAfter > 0 {
+		after := op.leakAfter
+		l.timer = time.AfterFunc(after, func() {
+			l.report("result set open too long", slog.Duration("open", after))
+		})
+	}
+	r.leak = l
+	runtime.SetFinalizer(r, func(r *loggedRows) {
+		l.report("result set garbage collected without Close")
+		// Release the connection held by the result set.
+		_ = r.ColumnScanner.Close()
+	})
+}
+
+// stop stops watching the result set once it is closed.
+func (l *rowsLeak) stop() {
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.once.Do(func() {})
+}
+
+// report logs a leak warning, at most once per result set.
+func (l *rowsLeak) report(msg string, attrs ...slog.Attr) {
+	l.once.Do(func() {
+		attrs = append(append(attrs, l.labels...), slog.String("operation", l.name), slog.String("query", scrubLiterals(l.query)))
+		if sc := trace.SpanContextFromContext(l.ctx); sc.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+		}
+		if l.caller != "" {
+			attrs = append(attrs, slog.String("caller", l.caller))
+		}
+		l.h.logAt(l.ctx, slog.LevelWarn, msg, attrs...)
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// subStatement is a statement of a multi-statement query.
+type subStatement struct {
+	text   string  // text of the statement, without the separating semicolon.
+	tokens []token // significant tokens of the statement.
+}
+
+// splitStatements splits a multi-statement query, such as a migration script, at the
+// semicolons separating its statements. Semicolons within literals, comments and the
+// BEGIN ... END bodies of triggers and routines do not separate statements.
+func splitStatements(query string) []string {
+	var texts []string
+	for _, s := range splitTokens(query, scanSQL(query)) {
+		texts = append(texts, s.text)
+	}
+	return texts
+}
+
+// splitTokens is splitStatements for the tokens of query, it keeps the significant
+// tokens of each statement.
+func splitTokens(query string, tokens []token) []subStatement {
+	var (
+		statements []subStatement
+		current    []token // significant tokens of the current statement.
+		start      int     // offset of the current statement.
+		pos        int     // offset of the current token.
+		depth      int     // nesting of BEGIN and CASE blocks.
+		first      = true  // whether the current token is the first word of the statement.
+		closed     bool    // whether the previous word was an END closing a block.
+		prev       string
+	)
+	for _, t := range tokens {
+		end := pos + len(t.text)
+		switch {
+		case t.kind == tokPunct && t.text == ";" && depth == 0:
+			if len(current) > 0 {
+				statements = append(statements, subStatement{text: strings.TrimSpace(query[start:pos]), tokens: current})
+			}
+			start, first, closed, prev, current = end, true, false, "", nil
+			pos = end
+			continue
+		case t.kind == tokWord:
+			word := strings.ToUpper(t.text)
+			wasClosed := closed
+			closed = false
+			switch {
+			case word == "BEGIN" && !first, word == "CASE" && prev != "END":
+				depth++
+			case word == "END" && depth > 0:
+				depth--
+				closed = true
+			case wasClosed && (word == "IF" || word == "LOOP" || word == "WHILE" || word == "REPEAT"):
+				// END IF and the like close constructs that are not counted, undo the decrement.
+				depth++
+			}
+			first, prev = false, word
+		case t.kind != tokSpace && t.kind != tokComment:
+			first, closed, prev = false, false, ""
+		}
+		if t.kind != tokSpace && t.kind != tokComment {
+			current = append(current, t)
+		}
+		pos = end
+	}
+	if len(current) > 0 {
+		statements = append(statements, subStatement{text: strings.TrimSpace(query[start:]), tokens: current})
+	}
+	return statements
+}
+
+// logStatements logs each statement of a multi-statement query in its own record.
+func (op *operation) logStatements() {
+	for i, statement := range op.split {
+		attrs := op.formatQuery(statement.text, op.hasSensitiveTable(tablesIn(statement.tokens)))
+		op.Log(op.ctx, op.name+" statement", append(attrs,
+			slog.Int("statement_index", i),
+			slog.Int("statement_count", len(op.split)),
+			slog.String("op", classOf(statement.tokens)),
+		)...)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			name:  "single",
+			query: "SELECT 1",
+			want:  []string{"SELECT 1"},
+		},
+		{
+			name:  "trailing semicolon and blanks",
+			query: "SELECT 1;\n\n;  SELECT 2;  ",
+			want:  []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name:  "semicolons in literals and comments",
+			query: "SELECT ';'; SELECT \";\" -- ;\n; /* ; */ SELECT 3",
+			want:  []string{"SELECT ';'", "SELECT \";\" -- ;", "/* ; */ SELECT 3"},
+		},
+		{
+			name:  "trigger body",
+			query: "CREATE TRIGGER tr BEFORE INSERT ON t FOR EACH ROW BEGIN SET NEW.a = 1; SET NEW.b = 2; END; INSERT INTO t VALUES (1)",
+			want: []string{
+				"CREATE TRIGGER tr BEFORE INSERT ON t FOR EACH ROW BEGIN SET NEW.a = 1; SET NEW.b = 2; END",
+				"INSERT INTO t VALUES (1)",
+			},
+		},
+		{
+			name:  "end if",
+			query: "CREATE TRIGGER tr BEFORE INSERT ON t FOR EACH ROW BEGIN IF NEW.a < 0 THEN SET NEW.a = 0; END IF; SET NEW.b = 1; END; SELECT 1",
+			want: []string{
+				"CREATE TRIGGER tr BEFORE INSERT ON t FOR EACH ROW BEGIN IF NEW.a < 0 THEN SET NEW.a = 0; END IF; SET NEW.b = 1; END",
+				"SELECT 1",
+			},
+		},
+		{
+			name:  "end while",
+			query: "CREATE FUNCTION f() RETURNS int BEGIN WHILE x DO SET x = x - 1; END WHILE; RETURN 1; END; SELECT 1",
+			want: []string{
+				"CREATE FUNCTION f() RETURNS int BEGIN WHILE x DO SET x = x - 1; END WHILE; RETURN 1; END",
+				"SELECT 1",
+			},
+		},
+		{
+			name:  "case expression",
+			query: "BEGIN; UPDATE t SET a = CASE WHEN b THEN 1 ELSE 2 END; COMMIT;",
+			want:  []string{"BEGIN", "UPDATE t SET a = CASE WHEN b THEN 1 ELSE 2 END", "COMMIT"},
+		},
+		{
+			name:  "nested blocks",
+			query: "CREATE PROCEDURE p() BEGIN BEGIN SELECT 1; END; SELECT 2; END; CALL p()",
+			want:  []string{"CREATE PROCEDURE p() BEGIN BEGIN SELECT 1; END; SELECT 2; END", "CALL p()"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("splitStatements(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// inflightOps tracks the operations running on the underlying driver, so notices can be
+// attributed to the statement that raised them.
+type inflightOps struct {
+	mu  sync.Mutex
+	ops map[*operation]struct{}
+}
+
+func newInflightOps() *inflightOps {
+	return &inflightOps{ops: make(map[*operation]struct{})}
+}
+
+func (f *inflightOps) add(op *operation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ops[op] = struct{}{}
+}
+
+func (f *inflightOps) remove(op *operation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.ops, op)
+}
+
+// only returns the running operation when there is exactly one.
+func (f *inflightOps) only() (*operation, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.ops) != 1 {
+		return nil, false
+	}
+	for op := range f.ops {
+		return op, true
+	}
+	return nil, false
+}
+
+// noticeLevel maps the severity of a notice, such as the Postgres DEBUG1, LOG, INFO,
+// NOTICE and WARNING severities or the MySQL Note and Warning levels, to a log level.
+func noticeLevel(severity string) slog.Level {
+	switch s := strings.ToUpper(severity); {
+	case strings.HasPrefix(s, "DEBUG"):
+		return slog.LevelDebug
+	case s == "WARNING":
+		return slog.LevelWarn
+	case s == "ERROR" || s == "FATAL" || s == "PANIC":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// LogNotice logs a notice or warning delivered asynchronously by the database driver,
+// such as the output of RAISE NOTICE in Postgres triggers and functions, at the level
+// matching its severity. It is meant to be called from the notice callback of the driver:
+//
+//	connector, _ := pq.NewConnector(dsn)
+//	var drv *entslog.SlogDriver
+//	db := sql.OpenDB(pq.ConnectorWithNoticeHandler(connector, func(n *pq.Error) {
+//		drv.LogNotice(context.Background(), n.Severity, n.Message, slog.String("where", n.Where))
+//	}))
+//	drv = entslog.New(entsql.OpenDB(dialect.Postgres, db), entslog.WithNoticeAttribution()).(*entslog.SlogDriver)
+//
+// With WithNoticeAttribution, notices arriving while a single operation is running are
+// logged with the attributes of that operation, its transaction and its context, which
+// also covers the notices of deferred triggers raised by a commit.
+func (d *SlogDriver) LogNotice(ctx context.Context, severity, message string, attrs ...slog.Attr) {
+	attrs = append([]slog.Attr{slog.String("severity", severity), slog.String("notice", message)}, attrs...)
+	h := d.handler()
+	if h.inflight != nil {
+		if op, ok := h.inflight.only(); ok {
+			if op.query != "" {
+				attrs = append(attrs, op.queryAttrs(op.sensitive)...)
+			}
+			attrs = append(attrs, op.labelAttrs()...)
+			op.logAt(op.ctx, noticeLevel(severity), op.name+" notice", attrs...)
+			return
+		}
+	}
+	h.logAt(ctx, noticeLevel(severity), "driver notice", attrs...)
+}
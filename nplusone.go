@@ -0,0 +1,96 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// queryCounter counts the executions of each fingerprint within a request or transaction.
+type queryCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+	total  int // executions of all fingerprints.
+}
+
+func newQueryCounter() *queryCounter {
+	return &queryCounter{counts: make(map[string]int)}
+}
+
+// add counts an execution of fp and returns the number of executions of fp so far.
+func (c *queryCounter) add(fp string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[fp]++
+	return c.counts[fp]
+}
+
+// inc counts an execution and returns the number of executions so far.
+func (c *queryCounter) inc() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total++
+	return c.total
+}
+
+// queryCounterKey is the context key under which ContextWithQueryCounting stores the counter.
+type queryCounterKey struct{}
+
+// ContextWithQueryCounting returns a copy of ctx under which, and under contexts derived from
+// it, statements are counted for the N+1 detection of WithNPlusOneDetection and the budget
+// of WithQueryBudget. It is meant to be called once per request, e.g. by an HTTP middleware.
+func ContextWithQueryCounting(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryCounterKey{}, newQueryCounter())
+}
+
+// detectNPlusOne warns when the statement fingerprint executes more than the threshold
+// within the transaction or the counted context, once per fingerprint and scope.
+func (op *operation) detectNPlusOne() {
+	if op.nPlusOne <= 0 || op.query == "" {
+		return
+	}
+	counter, _ := op.ctx.Value(queryCounterKey{}).(*queryCounter)
+	if counter == nil && op.txCounts == nil {
+		return
+	}
+	fp := op.fingerprint()
+	if op.txCounts != nil {
+		if n := op.txCounts.add(fp); n == op.nPlusOne+1 {
+			op.warnNPlusOne("transaction", n)
+		}
+	}
+	if counter != nil {
+		if n := counter.add(fp); n == op.nPlusOne+1 {
+			op.warnNPlusOne("context", n)
+		}
+	}
+}
+
+// checkBudget warns once when the number of statements executed under the counted
+// context exceeds the query budget.
+func (op *operation) checkBudget() {
+	if op.queryBudget <= 0 || op.query == "" {
+		return
+	}
+	counter, ok := op.ctx.Value(queryCounterKey{}).(*queryCounter)
+	if !ok {
+		return
+	}
+	if n := counter.inc(); n == op.queryBudget+1 {
+		op.logAt(op.ctx, slog.LevelWarn, "query budget exceeded", append(op.subjectAttrs(),
+			slog.Int("budget", op.queryBudget),
+			slog.Int("count", n),
+		)...)
+	}
+}
+
+func (op *operation) warnNPlusOne(scope string, count int) {
+	op.logAt(op.ctx, slog.LevelWarn, "repeated query, possible N+1 pattern", append(op.subjectAttrs(),
+		slog.String("scope", scope),
+		slog.Int("count", count),
+		slog.Int("threshold", op.nPlusOne),
+	)...)
+}
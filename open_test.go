@@ -0,0 +1,74 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	stdsql "database/sql"
+	"strings"
+	"testing"
+
+	"entgo.io/ent/dialect"
+)
+
+func init() {
+	stdsql.Register("entslogtest", testConnector{})
+}
+
+func TestDSNTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  string
+		dsn      string
+		host     string
+		database string
+	}{
+		{"mysql", dialect.MySQL, "root:secret@tcp(db:3306)/app?parseTime=true", "db:3306", "app"},
+		{"mysql question mark in password", dialect.MySQL, "root:p?ss@tcp(db:3306)/app", "db:3306", "app"},
+		{"mysql slash in password", dialect.MySQL, "root:p/ss@tcp(db:3306)/app?parseTime=true", "db:3306", "app"},
+		{"mysql at sign in password", dialect.MySQL, "root:p@ss@tcp(db:3306)/app", "db:3306", "app"},
+		{"mysql all in password", dialect.MySQL, "root:a?b/c@d@unix(/tmp/mysql.sock)/app", "/tmp/mysql.sock", "app"},
+		{"mysql without credentials", dialect.MySQL, "tcp(db:3306)/app", "db:3306", "app"},
+		{"mysql without address", dialect.MySQL, "/app", "", "app"},
+		{"mysql credentials without address", dialect.MySQL, "root:secret/app", "", "app"},
+		{"url", dialect.Postgres, "postgres://user:secret@db:5432/app?sslmode=disable", "db:5432", "app"},
+		{"url question mark in password", dialect.Postgres, "postgres://user:p?ss@db:5432/app", "db:5432", "app"},
+		{"url slash in password", dialect.Postgres, "postgres://user:p/ss@db:5432/app", "db:5432", "app"},
+		{"url at sign in password", dialect.Postgres, "postgres://user:p@ss@db/app?sslmode=disable", "db", "app"},
+		{"url without credentials", dialect.Postgres, "postgres://db/app", "db", "app"},
+		{"key value", dialect.Postgres, "host=db port=5432 dbname=app user=u password=secret", "db:5432", "app"},
+		{"sqlite", dialect.SQLite, "file:ent.db?cache=shared&_fk=1", "", "ent.db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, database := dsnTarget(tt.dialect, tt.dsn)
+			if host != tt.host || database != tt.database {
+				t.Errorf("dsnTarget(%q) = %q, %q, want %q, %q", tt.dsn, host, database, tt.host, tt.database)
+			}
+			for _, secret := range []string{"secret", "p?ss", "p/ss", "p@ss", "a?b"} {
+				if strings.Contains(host, secret) || strings.Contains(database, secret) {
+					t.Errorf("dsnTarget(%q) = %q, %q, leaking %q", tt.dsn, host, database, secret)
+				}
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	var log testLog
+	drv, err := Open("entslogtest", "root:p?ss@tcp(db:3306)/app", WithLogger(log.Logger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer drv.Close()
+	records := log.Records(t, "database opened")
+	if len(records) != 1 || records[0]["host"] != "db:3306" || records[0]["db_name"] != "app" {
+		t.Fatalf("records = %v", records)
+	}
+	if strings.Contains(log.buf.String(), "p?ss") {
+		t.Errorf("the password is logged: %s", log.buf.String())
+	}
+	if _, err := Open("entslogunknown", "", WithLogger(log.Logger())); err == nil {
+		t.Error("Open of an unregistered driver succeeded")
+	}
+}
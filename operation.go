@@ -0,0 +1,360 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// operation tracks a single call into the underlying driver from start to completion.
+type operation struct {
+	*Handler
+	ctx    context.Context // context handed to the underlying driver.
+	name   string          // operation name, used as the message of the operation records.
+	query  string          // statement sent to the underlying driver, empty for transaction control.
+	class  string          // statement class reported by the op attribute, empty for transaction control.
+	tables []string        // tables touched by the statement, reported by the tables attribute.
+	digest string          // fingerprint of the statement, empty unless WithQueryFingerprint is set.
+	hash   string          // hash of the fingerprint, empty unless WithQueryHash is set.
+	split  []subStatement  // statements of a multi-statement query, nil for single statements.
+	tokens []token         // significant tokens of the statement, scanned once by begin.
+	fp     string          // fingerprint of the statement, computed on first use by fingerprint.
+	origin string          // hash of the fingerprint and calling package, empty unless WithStatementOriginHash is set.
+	cancel *cancelWatch    // records the cancellation of the context, nil unless WithCancellationPropagationCheck is set.
+	probe  *connProbe      // connection acquisition probe, nil unless WithConnSource is set.
+	span   trace.Span      // span covering the operation, nil unless WithOTelTracing is set.
+	start  time.Time       // time the operation started.
+	took   time.Duration   // duration of the underlying call, set by finish.
+
+	parent  context.Context // context whose profiler labels are restored by finish, nil unless WithPprofLabels is set.
+	results []slog.Attr     // attributes describing the result of the call, e.g. rows_affected.
+	args    any             // arguments of the statement, kept for the EXPLAIN of slow statements.
+	diffs   []FieldDiff     // field diffs of the statement as logged, kept for the audit trail.
+	eventID string          // ordering id of the completion event, empty unless WithEventClock is set.
+
+	sensitive bool // whether the statement touches a sensitive table.
+}
+
+// begin prepares an operation, the returned operation's context must be passed to the underlying driver.
+func (h *Handler) begin(ctx context.Context, name, query string) *operation {
+	h.auditContext(ctx, name)
+	// The query is scanned once, its tokens are shared by the statement analyses.
+	var raw, tokens []token
+	var class string
+	if query != "" {
+		raw = scanSQL(query)
+		tokens = significant(raw)
+		class = classOf(tokens)
+	}
+	level, leveled := levelFromContext(ctx)
+	if !leveled && h.ddlLevel != nil && class == ClassDDL {
+		level, leveled = h.ddlLevel, true
+	}
+	if !leveled {
+		// A nil level set by WithLevelFor keeps the default level.
+		level = h.opLevels[name]
+		leveled = level != nil
+	}
+	muted := silenced(ctx) || (h.ops != nil && !h.ops.allows(name)) || h.ignoresQuery(query)
+	if leveled || muted {
+		hc := *h
+		if leveled {
+			hc.level = level
+		}
+		hc.muted = muted
+		h = &hc
+	}
+	op := &operation{Handler: h, ctx: ctx, name: name, query: query, class: class, tokens: tokens, start: h.now()}
+	if query != "" {
+		op.tables = tablesIn(tokens)
+		op.sensitive = h.hasSensitiveTable(op.tables)
+		if strings.IndexByte(query, ';') >= 0 {
+			if statements := splitTokens(query, raw); len(statements) > 1 {
+				op.split = statements
+			}
+		}
+		if h.fingerprints || h.queryHash || h.originHash {
+			fp := op.fingerprint()
+			if h.fingerprints {
+				op.digest = fp
+			}
+			if h.queryHash {
+				op.hash = queryHash(fp)
+			}
+			if h.originHash {
+				op.origin = originHash(fp)
+			}
+		}
+	}
+	if h.cancelGrace > 0 {
+		op.cancel = watchCancel(ctx, h.now)
+	}
+	if h.tracer != nil {
+		op.ctx, op.span = h.startSpan(op.ctx, name, query)
+	}
+	if h.pprofLabels {
+		op.parent = op.ctx
+		op.ctx = pprof.WithLabels(op.ctx, op.profilerLabels())
+		pprof.SetGoroutineLabels(op.ctx)
+	}
+	if h.connSource {
+		op.probe = new(connProbe)
+		op.ctx = context.WithValue(op.ctx, connProbeKey{}, op.probe)
+	}
+	if h.inflight != nil {
+		// Notices read the operation from another goroutine, it is shared once built.
+		h.inflight.add(op)
+	}
+	return op
+}
+
+// fingerprint returns the fingerprint of the statement, computed from its tokens on first use.
+func (op *operation) fingerprint() string {
+	if op.fp == "" && op.query != "" {
+		op.fp = fingerprintOf(op.tokens)
+	}
+	return op.fp
+}
+
+// statement returns the query to send to the underlying driver, which may differ
+// from the logged query when statement comments are enabled.
+func (op *operation) statement() string {
+	if !op.sqlCommenter {
+		return op.query
+	}
+	return withComment(op.query, op.sqlComment(op.ctx))
+}
+
+// profilerLabels returns the pprof labels of the operation: the SQL operation and the
+// first table the statement touches.
+func (op *operation) profilerLabels() pprof.LabelSet {
+	sqlOp := dbOperation(Event{Op: op.name, Query: op.query, Fingerprint: op.fingerprint()})
+	if len(op.tables) > 0 {
+		return pprof.Labels("sql_op", sqlOp, "table", op.tables[0])
+	}
+	return pprof.Labels("sql_op", sqlOp)
+}
+
+// logStatement logs the statement the operation is about to send to the underlying driver.
+func (op *operation) logStatement(args any) {
+	op.args = args
+	op.Log(op.ctx, op.name, op.statementAttrs(args)...)
+	if op.splitLogging && op.split != nil {
+		op.logStatements()
+	}
+	op.logFirstSeen()
+	op.detectNPlusOne()
+	op.checkBudget()
+	if op.interpolate && !op.omitArgs && op.argSalt == nil {
+		op.logInterpolated(args)
+	}
+}
+
+// statementAttrs returns the attributes describing the statement and its arguments.
+func (op *operation) statementAttrs(args any) []slog.Attr {
+	sensitive := op.sensitive
+	attrs := op.queryAttrs(sensitive)
+	switch {
+	case op.omitArgs:
+	case sensitive:
+		attrs = append(attrs, slog.Any("args", redactArgs(args)))
+	default:
+		attrs = append(attrs, op.argsAttrs(op.filterArgs(args))...)
+	}
+	if sensitive {
+		attrs = append(attrs, slog.Bool("sensitive", true))
+	}
+	attrs = append(attrs, op.labelAttrs()...)
+	if op.split != nil {
+		attrs = append(attrs, slog.Int("statement_count", len(op.split)))
+	}
+	if diff, ok := op.diffAttr(args, sensitive || op.omitArgs); ok {
+		attrs = append(attrs, diff)
+	}
+	return attrs
+}
+
+// queryAttrs returns the query attribute of records, with the literals of the query
+// scrubbed when it touches a sensitive table, and the truncation attributes.
+func (op *operation) queryAttrs(sensitive bool) []slog.Attr {
+	return op.formatQuery(op.query, sensitive)
+}
+
+// formatQuery returns the query attribute of records for query, see queryAttrs.
+func (op *operation) formatQuery(query string, sensitive bool) []slog.Attr {
+	original := query
+	if sensitive {
+		query = scrubLiterals(query)
+	}
+	if op.queryFilter != nil {
+		query = op.queryFilter(op.ctx, query)
+	}
+	switch {
+	case op.pretty:
+		query = prettySQL(query, op.prettyColor)
+	case op.normalizeQuery:
+		query = collapseSpace(query)
+	}
+	if op.maxQueryLength <= 0 || len(query) <= op.maxQueryLength {
+		return []slog.Attr{slog.String("query", query)}
+	}
+	return []slog.Attr{
+		slog.String("query", truncate(query, op.maxQueryLength)+truncationMarker),
+		slog.Bool("query_truncated", true),
+		slog.Int("query_length", len(original)),
+	}
+}
+
+// finish completes the operation and returns the attributes that are only known
+// once the underlying call has returned.
+func (op *operation) finish(err error) []slog.Attr {
+	op.took = op.now().Sub(op.start)
+	if op.inflight != nil {
+		op.inflight.remove(op)
+	}
+	if op.eventIDs {
+		op.eventID = nextEventID()
+	}
+	op.checkCancellation(op.start.Add(op.took))
+	if op.parent != nil {
+		pprof.SetGoroutineLabels(op.parent)
+	}
+	if op.query != "" {
+		op.observeSLO()
+		if op.txSummary != nil {
+			op.txSummary.observe(err)
+		}
+	}
+	op.observeVolume()
+	op.recordHistory(err)
+	if len(op.observers) > 0 {
+		e := op.event(err)
+		for _, o := range op.observers {
+			o.Observe(op.ctx, e)
+		}
+	}
+	if op.span != nil {
+		endSpan(op.span, err)
+	}
+	var attrs []slog.Attr
+	if op.probe != nil {
+		attrs = append(attrs, op.probe.attrs()...)
+	}
+	return append(attrs, op.results...)
+}
+
+// event returns the Event describing the finished operation.
+func (op *operation) event(err error) Event {
+	return Event{Op: op.name, Query: op.query, Fingerprint: op.fingerprint(), Class: op.class, Tables: op.tables, TxID: op.txID,
+		Start: op.start, Duration: op.took, Err: err, ID: op.eventID}
+}
+
+// slow reports whether the operation was a statement exceeding the slow threshold.
+func (op *operation) slow() bool {
+	return op.slowThreshold > 0 && op.query != "" && op.took > op.slowThreshold
+}
+
+// labelAttrs returns the op, phase, ddl, tables, fingerprint, query_hash and origin_hash attributes of statements,
+// which records are most commonly filtered and aggregated by.
+func (op *operation) labelAttrs() []slog.Attr {
+	if op.class == "" {
+		return nil
+	}
+	attrs := []slog.Attr{slog.String("op", op.class)}
+	if op.class == ClassDDL {
+		// Schema migrations, e.g. of ent's schema.Create, run DDL statements.
+		attrs = append(attrs, slog.String("phase", "migration"), slog.Bool("ddl", true))
+	}
+	if len(op.tables) > 0 {
+		attrs = append(attrs, slog.Any("tables", op.tables))
+	}
+	if op.digest != "" {
+		attrs = append(attrs, slog.String("fingerprint", op.digest))
+	}
+	if op.hash != "" {
+		attrs = append(attrs, slog.String("query_hash", op.hash))
+	}
+	if op.origin != "" {
+		attrs = append(attrs, slog.String("origin_hash", op.origin))
+	}
+	return attrs
+}
+
+// subjectAttrs returns the labels of the operation along with the fingerprint of its
+// statement, which labelAttrs only carries with WithQueryFingerprint, for the records
+// following up on the statement, such as plans, warnings and result set records.
+func (op *operation) subjectAttrs() []slog.Attr {
+	attrs := op.labelAttrs()
+	if op.digest == "" && op.query != "" {
+		attrs = append(attrs, slog.String("fingerprint", op.fingerprint()))
+	}
+	return attrs
+}
+
+// quietLifecycle reports whether the operation starts or ends a transaction and
+// WithoutTxLogging is set.
+func (op *operation) quietLifecycle() bool {
+	return op.noTxLogs && op.query == ""
+}
+
+// logLifecycle logs a transaction lifecycle record, unless WithoutTxLogging is set.
+func (op *operation) logLifecycle(msg string, attrs ...slog.Attr) {
+	if !op.quietLifecycle() {
+		op.Log(op.ctx, msg, attrs...)
+	}
+}
+
+// end finishes the operation, logs its outcome and returns err unchanged.
+func (op *operation) end(err error) error {
+	result := op.finish(err)
+	op.explainSlow(err)
+	op.analyzeSlow(err)
+	op.checkReadOnly(err)
+	op.auditMutation(err)
+	attrs := append(op.labelAttrs(), result...)
+	if op.levelFunc != nil {
+		return op.endAt(op.levelFunc(op.ctx, op.event(err)), err, attrs)
+	}
+	if err != nil {
+		return op.LogError(op.ctx, op.name, err, attrs...)
+	}
+	if op.slow() {
+		attrs = append(append(op.queryAttrs(op.sensitive),
+			slog.Duration("duration", op.took),
+			slog.Bool("slow", true),
+		), attrs...)
+		op.logAt(op.ctx, slog.LevelWarn, op.name+" done", attrs...)
+		return nil
+	}
+	if len(result) > 0 && !op.quietLifecycle() {
+		op.Log(op.ctx, op.name+" done", attrs...)
+	}
+	return nil
+}
+
+// endAt logs the outcome of the operation at the level computed by the level function.
+// Unlike end, the outcome of every operation is logged, along with its duration.
+func (op *operation) endAt(level slog.Level, err error, attrs []slog.Attr) error {
+	if (op.txSummary != nil || op.muted || op.quietLifecycle()) && err == nil && level < slog.LevelWarn {
+		return nil
+	}
+	attrs = append([]slog.Attr{slog.Duration("duration", op.took)}, attrs...)
+	if err != nil {
+		if op.handleError && (op.errFilter == nil || op.errFilter(op.ctx, err)) {
+			op.logAt(op.ctx, level, op.name, append([]slog.Attr{slog.Any("error", err)}, attrs...)...)
+		}
+		return err
+	}
+	if op.slow() {
+		attrs = append(op.queryAttrs(op.sensitive), append(attrs, slog.Bool("slow", true))...)
+	}
+	op.logAt(op.ctx, level, op.name+" done", attrs...)
+	return nil
+}
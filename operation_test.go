@@ -0,0 +1,158 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+)
+
+// cancelingDriver is a fake driver canceling the context of its statements as they
+// start, once the driver recorded the cancellation time, so they outlive it by took.
+type cancelingDriver struct {
+	*fakeDriver
+	cancel context.CancelFunc
+	reads  atomic.Int64 // reads of the clock, one of which records the cancellation time.
+}
+
+func newCancelingDriver(took time.Duration, cancel context.CancelFunc) *cancelingDriver {
+	return &cancelingDriver{fakeDriver: &fakeDriver{clock: newTestClock(), took: took}, cancel: cancel}
+}
+
+// Now returns the time of the clock of the fake driver.
+func (d *cancelingDriver) Now() time.Time {
+	d.reads.Add(1)
+	return d.clock.Now()
+}
+
+func (d *cancelingDriver) Exec(ctx context.Context, query string, args, v any) error {
+	reads := d.reads.Load()
+	d.cancel()
+	for deadline := time.Now().Add(5 * time.Second); d.reads.Load() == reads && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	return d.fakeDriver.Exec(ctx, query, args, v)
+}
+
+func TestSlowThreshold(t *testing.T) {
+	tests := []struct {
+		name string
+		took time.Duration
+		slow bool
+	}{
+		{"fast", 50 * time.Millisecond, false},
+		{"at threshold", 100 * time.Millisecond, false},
+		{"slow", 150 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log testLog
+			fake := &fakeDriver{clock: newTestClock(), took: tt.took}
+			drv := newTestDriver(fake, &log, WithSlowThreshold(100*time.Millisecond))
+			if err := drv.Exec(context.Background(), "UPDATE t SET a = ?", []any{1}, nil); err != nil {
+				t.Fatal(err)
+			}
+			records := log.Records(t, "Exec done")
+			if !tt.slow {
+				for _, record := range records {
+					if record["slow"] != nil {
+						t.Errorf("record %v is slow, took %v", record, tt.took)
+					}
+				}
+				return
+			}
+			if len(records) != 1 {
+				t.Fatalf("got %d slow records, want 1", len(records))
+			}
+			record := records[0]
+			if record["level"] != "WARN" || record["slow"] != true || record["query"] != "UPDATE t SET a = ?" {
+				t.Errorf("slow record = %v", record)
+			}
+			if got := time.Duration(record["duration"].(float64)); got != tt.took {
+				t.Errorf("duration = %v, want %v", got, tt.took)
+			}
+		})
+	}
+}
+
+func TestOperationRecordsCarryOp(t *testing.T) {
+	tests := []struct {
+		name  string
+		msg   string
+		key   string // attribute telling the record from others with the same message.
+		class string
+		run   func(t *testing.T, log *testLog)
+	}{
+		{"error", "Exec", "error", ClassUpdate, func(t *testing.T, log *testLog) {
+			fake := &fakeDriver{clock: newTestClock(), err: errors.New("deadlock")}
+			drv := newTestDriver(fake, log, WithQueryFingerprint())
+			_ = drv.Exec(context.Background(), "UPDATE t SET a = ?", []any{1}, nil)
+		}},
+		{"slow", "Exec done", "slow", ClassDelete, func(t *testing.T, log *testLog) {
+			fake := &fakeDriver{clock: newTestClock(), took: time.Second}
+			drv := newTestDriver(fake, log, WithSlowThreshold(100*time.Millisecond), WithQueryFingerprint())
+			if err := drv.Exec(context.Background(), "DELETE FROM t WHERE a = ?", []any{1}, nil); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"cancellation", "operation outlived context cancellation", "overrun", ClassInsert, func(t *testing.T, log *testLog) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			dri := newCancelingDriver(time.Second, cancel)
+			drv := New(dri, WithLogger(log.Logger()), WithClock(dri.Now), WithCancellationPropagationCheck(0))
+			if err := drv.Exec(ctx, "INSERT INTO t (a) VALUES (?)", []any{1}, nil); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"rows", "Query rows closed", "rows", ClassSelect, func(t *testing.T, log *testLog) {
+			drv := New(newTimedDriver("sqlite3", 0), WithLogger(log.Logger()), WithRowCounts())
+			var rows sql.Rows
+			if err := drv.Query(context.Background(), "SELECT a FROM t", []any{}, &rows); err != nil {
+				t.Fatal(err)
+			}
+			for rows.Next() {
+			}
+			rows.Close()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log testLog
+			tt.run(t, &log)
+			var found int
+			scanner := bufio.NewScanner(bytes.NewReader(log.buf.Bytes()))
+			for scanner.Scan() {
+				var record map[string]any
+				if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+					t.Fatal(err)
+				}
+				if record[slog.MessageKey] != tt.msg || record[tt.key] == nil {
+					continue
+				}
+				found++
+				if record["op"] != tt.class {
+					t.Errorf("record op = %v, want %q", record["op"], tt.class)
+				}
+				for _, key := range []string{"op", "fingerprint"} {
+					if n := strings.Count(scanner.Text(), `"`+key+`":`); n != 1 {
+						t.Errorf("record %s carries %s %d times", scanner.Text(), key, n)
+					}
+				}
+			}
+			if found != 1 {
+				t.Errorf("got %d %q records, want 1", found, tt.msg)
+			}
+		})
+	}
+}
@@ -5,9 +5,18 @@ package entslog
 
 import (
 	"context"
+	"io"
 	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/goexts/generic/settings"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type (
@@ -15,6 +24,14 @@ type (
 	TraceFunc func(context.Context) string
 	// FilterAttrs defines a function to filter out attributes from log entries.
 	FilterAttrs func(context.Context, ...slog.Attr) []slog.Attr
+	// ErrorFilterFunc reports whether err is logged.
+	ErrorFilterFunc func(ctx context.Context, err error) bool
+	// MessageFunc returns the message logged for a record of the driver, e.g. "Exec" or "Tx started".
+	MessageFunc func(msg string) string
+	// ErrorLevelFunc returns the level of the record logging err, nil for the error level.
+	ErrorLevelFunc func(err error) slog.Leveler
+	// ContextAttrsFunc returns the attributes derived from the context of a record, e.g. its tenant id.
+	ContextAttrsFunc func(ctx context.Context) []slog.Attr
 	// Option defines configuration options for the logging handler.
 	Option struct {
 		handleError bool         // HandleError determines whether errors encountered during logging are handled.
@@ -23,6 +40,91 @@ type (
 		errorLevel  slog.Leveler // ErrorLevel specifies the log level for error messages.
 		trace       TraceFunc    // GenerateID is a function to generate unique IDs for log entries.
 		filter      FilterAttrs  // Filters specifies the set of attributes to filter out from logged messages.
+		async       int          // Async specifies the buffer size of the asynchronous log queue, zero logs synchronously.
+		connSource  bool         // ConnSource determines whether connection acquisition (pool or dial) is logged.
+		tracer      trace.Tracer // Tracer creates an OpenTelemetry span for every operation when set.
+
+		shutdownTimeout time.Duration    // ShutdownTimeout bounds the time Close spends draining the asynchronous queue.
+		sensitiveTables map[string]bool  // SensitiveTables lists the tables whose statements are always redacted.
+		sensitiveCols   map[string]bool  // SensitiveCols lists the columns whose bound arguments are always redacted.
+		slos            *sloSet          // SLOs holds the latency objectives whose burn rate is tracked.
+		sqlCommenter    bool             // SQLCommenter determines whether outgoing queries carry a sqlcommenter comment.
+		application     string           // Application is the application name reported in sqlcommenter comments.
+		slowThreshold   time.Duration    // SlowThreshold is the duration above which statements are logged as slow.
+		observers       []Observer       // Observers receive an Event for every completed operation.
+		meter           metric.Meter     // Meter records OpenTelemetry metrics for every operation when set.
+		mutationDiff    MutationDiffFunc // MutationDiff supplies field diffs attached to UPDATE statements in transactions.
+		expvarName      string           // ExpvarName is the name under which operation counters are published.
+		profile         SchemaProfile    // Profile selects the attribute naming convention of the records.
+		dbStatsInterval time.Duration    // DBStatsInterval is the interval at which connection pool statistics are logged.
+		scheduler       *Scheduler       // Scheduler runs the background reporters, nil gives each its own goroutine.
+		contextAudit    bool             // ContextAudit determines whether operations without request context are reported.
+		pprofLabels     bool             // PprofLabels determines whether operations run under pprof labels.
+		datadogIDs      TraceIDsFunc     // DatadogIDs extracts the ids of the dd.trace_id and dd.span_id attributes.
+		resultSchema    bool             // ResultSchema determines whether result set columns are logged once per fingerprint.
+		fingerprints    bool             // Fingerprints determines whether statement records carry a fingerprint attribute.
+		queryHash       bool             // QueryHash determines whether statement records carry a query_hash attribute.
+		normalizeQuery  bool             // NormalizeQuery determines whether the whitespace of logged queries is collapsed.
+		pretty          bool             // Pretty determines whether logged queries are reindented.
+		prettyColor     bool             // PrettyColor determines whether reindented queries are colorized.
+		interpolate     bool             // Interpolate determines whether statements are also logged with their arguments substituted.
+		maxQueryLength  int              // MaxQueryLength is the length in bytes above which logged queries are truncated.
+		execResult      bool             // ExecResult determines whether the rows affected and last insert id of Exec are logged.
+		eventIDs        bool             // EventIDs determines whether records and events carry an ordering id.
+		rowCounts       bool             // RowCounts determines whether the size of result sets is logged on Close.
+		firstSeen       int              // FirstSeen is the number of fingerprints remembered to detect new queries.
+		leakCheck       bool             // LeakCheck determines whether result sets that are not closed are reported.
+		leakAfter       time.Duration    // LeakAfter is the time after which a result set still open is reported.
+		nPlusOne        int              // NPlusOne is the number of executions of a fingerprint tolerated per request or transaction.
+		queryBudget     int              // QueryBudget is the number of statements tolerated per request.
+		explainInterval time.Duration    // ExplainInterval is the minimum interval between two EXPLAIN statements.
+		notices         bool             // Notices attributes the notices logged by LogNotice to the running operation.
+		splitLogging    bool             // SplitLogging logs each statement of multi-statement queries in its own record.
+		originHash      bool             // OriginHash adds the hash of the fingerprint and calling package to statement records.
+		omitArgs        bool             // OmitArgs never logs the arguments of statements.
+		volumeFactor    float64          // VolumeFactor is the ratio of the rate of a fingerprint to its baseline above which a spike is logged.
+		volumeWindow    time.Duration    // VolumeWindow is the period over which the executions of fingerprints are counted.
+		argFilter       ArgFilterFunc    // ArgFilter returns the logged value of each bind argument.
+		classifier      Classifier       // Classifier labels the sensitive bind arguments, which are masked.
+		queryFilter     QueryFilterFunc  // QueryFilter returns the logged text of each query.
+		maxLoggedTx     int              // MaxLoggedTx is the maximum number of concurrent transactions whose statements are logged.
+		maxArgSize      int              // MaxArgSize is the length above which byte slice and string arguments are summarized.
+		readOnlyCheck   bool             // ReadOnlyCheck detects writes succeeding in read-only transactions.
+		maxArgsSize     int              // MaxArgsSize is the formatted size above which the args attribute is truncated.
+		auditSink       io.Writer        // AuditSink receives the hash-chained mutation audit trail.
+		argSalt         []byte           // ArgSalt is the key of the salted hashes replacing the arguments.
+		development     bool             // Development is set by the Development preset and cleared by the Production one.
+		analyzeAfter    time.Duration    // AnalyzeAfter is the duration above which statements are analyzed in development.
+		sanitizers      []Sanitizer      // Sanitizers mask the personal data of the arguments, in order.
+		recordChain     bool             // RecordChain determines whether records are linked by hashes.
+		attrs           []slog.Attr      // Attrs are the attributes of every record of the driver.
+		group           string           // Group is the group nesting the attributes of every record.
+		opLevels        levelMap         // OpLevels are the default levels by operation name.
+		errLevelFunc    ErrorLevelFunc   // ErrLevelFunc computes the level of the records logging errors.
+		benign          []BenignFunc     // Benign match the errors logged at Debug level.
+		errFilter       ErrorFilterFunc  // ErrFilter reports whether an error is logged.
+		noTxLogs        bool             // NoTxLogs suppresses the transaction lifecycle records.
+		ops             *opSet           // Ops selects the operations whose records are logged.
+		ignored         []*regexp.Regexp // Ignored matches the queries whose records are not logged.
+		messageFunc     MessageFunc      // MessageFunc returns the logged message of every record.
+		msgPrefix       string           // MsgPrefix prefixes the message of every record.
+		clock           func() time.Time // Clock returns the current time of the durations.
+		ctxAttrs        ContextAttrsFunc // CtxAttrs returns the attributes derived from the context of every record.
+		noDialect       bool             // NoDialect removes the dialect attribute of every record.
+		ctxTrace        bool             // CtxTrace determines whether records carry the trace id of their context.
+		ddlLevel        slog.Leveler     // DDLLevel is the default level of the records of DDL statements.
+		bridgeMethods   bool             // BridgeMethods determines whether the context methods missing from the driver are bridged.
+		scanErrors      bool             // ScanErrors determines whether scan errors are logged with the column details.
+		levelFunc       LevelFunc        // LevelFunc computes the level of the record reporting the outcome of an operation.
+		sessionTags     bool             // SessionTags determines whether transactions store their request id in a session variable.
+		sessionTag      SessionTagFunc   // SessionTag returns the request id stored in the session variable.
+		cancelGrace     time.Duration    // CancelGrace is the time an operation may outlive its context cancellation before a warning.
+		historySize     int              // HistorySize is the number of statements kept by the query history.
+		queryStore      QueryStore       // QueryStore holds the query texts of the query history.
+		reportWriter    io.Writer        // ReportWriter receives the periodic plaintext reports.
+		reportInterval  time.Duration    // ReportInterval is the period covered by each plaintext report.
+		statsStore      StatsStore       // StatsStore persists the statistics across process restarts.
+		statsInterval   time.Duration    // StatsInterval is the interval at which statistics are saved.
 	}
 	// Setting is a type alias for the settings.Setting type.
 	Setting = func(*Option)
@@ -36,14 +138,21 @@ var defaultOption = Option{
 	handleError: true,            // Defaults to handling errors.
 	filter:      emptyFilter,     // Defaults to no filtering.
 	trace:       traceUUID,       // Uses the package-level trace function to generate log entry IDs by default.
+	benign:      notFound,        // Logs not found errors at Debug level by default.
+
+	bridgeMethods: bridgeByDefault, // Bridges the missing context methods in builds tagged entslog_legacy_ent.
 }
 
 func emptyFilter(_ context.Context, attrs ...slog.Attr) []slog.Attr {
 	return attrs
 }
 
-// traceUUID generates a unique identifier for a log entry using UUIDs.
+// traceUUID generates a unique identifier for a log entry using UUIDs, or derives it from
+// the request id of EnsureRequestID when ctx carries one.
 func traceUUID(ctx context.Context) string {
+	if id, ok := derivedRequestID(ctx); ok {
+		return id
+	}
 	return uuid.Must(uuid.NewRandom()).String()
 }
 
@@ -109,6 +218,22 @@ func WithTrace(trace func(context.Context) string) Setting {
 	}
 }
 
+// WithContextTrace adds a `trace_id` attribute to every record, inside and outside of
+// transactions, so all the statements of a request can be linked. The id is generated,
+// or extracted, by the trace function of WithTrace once per root context marked with
+// ContextWithTrace. For contexts without a root, the trace function is called for each
+// record, which only links them when it extracts the id from the context, e.g. the
+// OpenTelemetry trace id. The request id of EnsureRequestID, when the context carries
+// one, is the trace id instead. Transactions keep their own id in the `id` attribute.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling the context trace ids,
+// and returns the updated `*Option` pointer.
+func WithContextTrace() Setting {
+	return func(option *Option) {
+		option.ctxTrace = true
+	}
+}
+
 // WithLogger specifies the logger to be used for logging.
 // If not specified, the default logger will be used.
 //
@@ -122,4 +247,1383 @@ func WithLogger(logger *slog.Logger) Setting {
 	}
 }
 
+// WithLoggers sends every record to each of the loggers, e.g. to a JSON production logger
+// and to a full-fidelity audit logger, each dropping the records below its own level.
+// It replaces the logger of WithLogger, and nil loggers are skipped.
+//
+// - `loggers`: The loggers receiving the records.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting a logger fanning out to the loggers,
+// and returns the updated `*Option` pointer.
+func WithLoggers(loggers ...*slog.Logger) Setting {
+	return func(option *Option) {
+		var handlers fanoutHandler
+		for _, logger := range loggers {
+			if logger != nil {
+				handlers = append(handlers, logger.Handler())
+			}
+		}
+		if len(handlers) > 0 {
+			option.logger = slog.New(handlers)
+		}
+	}
+}
+
+// WithAsync enables asynchronous logging through a queue of the given size.
+// Records are written by a background goroutine so slow slog handlers never block
+// query execution; records arriving while the queue is full are dropped. Errors, slow
+// statements and other warnings use a separate queue of the same size that is served
+// first, so they are never dropped because of routine records.
+// Call SlogDriver.Flush or SlogDriver.Close to drain the queue.
+//
+// - `bufferSize`: The number of records the queue can hold, values <= 0 keep logging synchronous.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the queue size,
+// and returns the updated `*Option` pointer.
+func WithAsync(bufferSize int) Setting {
+	return func(option *Option) {
+		option.async = bufferSize
+	}
+}
+
+// WithShutdownTimeout bounds the time SlogDriver.Close spends draining the asynchronous
+// log queue. Error-level records are always written first and are never discarded;
+// routine records still queued when the timeout expires are discarded, and the number
+// of dropped and discarded records is logged once the queue is closed.
+//
+// - `timeout`: The maximum drain duration, zero waits until the queue is empty.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the shutdown timeout,
+// and returns the updated `*Option` pointer.
+func WithShutdownTimeout(timeout time.Duration) Setting {
+	return func(option *Option) {
+		option.shutdownTimeout = timeout
+	}
+}
+
+// WithSlowThreshold logs statements taking longer than threshold in a completion record
+// at warning level, carrying the query, its duration and a `slow=true` attribute.
+// In asynchronous mode such records share the high-priority queue with errors.
+//
+// - `threshold`: The duration above which a statement is slow, zero disables slow logging.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the slow threshold,
+// and returns the updated `*Option` pointer.
+func WithSlowThreshold(threshold time.Duration) Setting {
+	return func(option *Option) {
+		option.slowThreshold = threshold
+	}
+}
+
+// WithConnSource logs whether each operation ran on a reused pooled connection or
+// triggered a fresh dial, together with the dial latency. Dials are only observable
+// when the *sql.DB was opened over a connector wrapped with Connector.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling connection source logging,
+// and returns the updated `*Option` pointer.
+func WithConnSource() Setting {
+	return func(option *Option) {
+		option.connSource = true
+	}
+}
+
+// WithOTelTracing creates an OpenTelemetry client span for every Exec, Query, Tx, Commit
+// and Rollback, carrying the db.system and db.statement semantic-convention attributes.
+// Spans are children of the span in the operation context, so they correlate with the
+// slog records emitted for the same operation.
+//
+// - `tracer`: The tracer used to start spans.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the tracer,
+// and returns the updated `*Option` pointer.
+func WithOTelTracing(tracer trace.Tracer) Setting {
+	return func(option *Option) {
+		option.tracer = tracer
+	}
+}
+
+// WithSensitiveTableList marks tables as sensitive. Any statement touching one of them
+// has its bind arguments redacted and its query literals scrubbed, and is tagged with a
+// `sensitive=true` attribute, regardless of any other argument logging setting.
+// Table names are matched case-insensitively, unqualified names match in every schema.
+//
+// - `tables`: The names of the sensitive tables, e.g. "users", "payments".
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by adding the sensitive tables,
+// and returns the updated `*Option` pointer.
+func WithSensitiveTableList(tables ...string) Setting {
+	return func(option *Option) {
+		if option.sensitiveTables == nil {
+			option.sensitiveTables = make(map[string]bool, len(tables))
+		}
+		for _, table := range tables {
+			option.sensitiveTables[strings.ToLower(table)] = true
+		}
+	}
+}
+
+// WithSensitiveColumnList marks columns as sensitive. The bind arguments of statements
+// bound to one of them, as in the column list of an INSERT, `SET password = ?` or
+// `WHERE token = ?`, are redacted wherever the statement is logged, and so are the
+// values of their mutation diffs. Column names are matched case-insensitively,
+// regardless of the table.
+//
+// - `columns`: The names of the sensitive columns, e.g. "password", "ssn".
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by adding the sensitive columns,
+// and returns the updated `*Option` pointer.
+func WithSensitiveColumnList(columns ...string) Setting {
+	return func(option *Option) {
+		if option.sensitiveCols == nil {
+			option.sensitiveCols = make(map[string]bool, len(columns))
+		}
+		for _, column := range columns {
+			option.sensitiveCols[strings.ToLower(column)] = true
+		}
+	}
+}
+
+// WithLatencySLO tracks the error-budget burn rate of a latency objective and logs a
+// warning when the burn rate within a window exceeds slo.BurnRate. Without queries the
+// objective applies to every statement; otherwise it applies to statements sharing the
+// fingerprint of one of the given queries (literals and formatting are ignored).
+//
+// - `slo`: The latency objective to track.
+// - `queries`: Optional queries the objective is scoped to.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by adding the objective,
+// and returns the updated `*Option` pointer.
+func WithLatencySLO(slo SLO, queries ...string) Setting {
+	return func(option *Option) {
+		if option.slos == nil {
+			option.slos = new(sloSet)
+		}
+		option.slos.add(slo, queries)
+	}
+}
+
+// WithSQLCommenter appends a sqlcommenter-style comment such as
+// `/*application='api',traceparent='00-...-...-01'*/` to every query before it is passed
+// to the underlying driver, so database-side slow query logs can be correlated with
+// application traces. The traceparent is taken from the OpenTelemetry span in the context,
+// which is the operation span when WithOTelTracing is set. Logged queries are not modified.
+//
+// - `application`: The application name to report, empty omits it.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling statement comments,
+// and returns the updated `*Option` pointer.
+func WithSQLCommenter(application string) Setting {
+	return func(option *Option) {
+		option.sqlCommenter = true
+		option.application = application
+	}
+}
+
+// WithObserver registers an Observer that receives an Event for every completed operation,
+// for example the Prometheus collector of the entprom package. It may be given multiple times.
+//
+// - `observer`: The observer to register.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by adding the observer,
+// and returns the updated `*Option` pointer.
+func WithObserver(observer Observer) Setting {
+	return func(option *Option) {
+		option.observers = append(option.observers, observer)
+	}
+}
+
+// WithOTelMetrics records every operation with OpenTelemetry metric instruments: a
+// `db.client.operation.duration` histogram and a `db.client.operation.errors` counter,
+// both carrying the db.system and db.operation attributes. It can be combined with the
+// Prometheus collector of the entprom package.
+//
+// - `meter`: The meter used to create the instruments.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the meter,
+// and returns the updated `*Option` pointer.
+func WithOTelMetrics(meter metric.Meter) Setting {
+	return func(option *Option) {
+		option.meter = meter
+	}
+}
+
+// WithMutationDiff attaches before/after field diffs to the records of UPDATE statements
+// executed inside a transaction, under a `diff` group. The diffs are supplied by fn, which
+// defaults to MutationDiffFromContext when nil so an ent hook can provide them through
+// ContextWithMutationDiff. Diff values of sensitive tables are redacted.
+//
+// - `fn`: The function supplying the diffs, or nil to read them from the context.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the diff hook,
+// and returns the updated `*Option` pointer.
+func WithMutationDiff(fn MutationDiffFunc) Setting {
+	return func(option *Option) {
+		if fn == nil {
+			fn = MutationDiffFromContext
+		}
+		option.mutationDiff = fn
+	}
+}
+
+// WithExpvar publishes per-operation counters (exec, query, tx, commit, rollback and errors)
+// as an expvar.Map under name, so existing /debug/vars scraping picks them up. Drivers
+// configured with the same name share the counters.
+//
+// - `name`: The expvar name to publish the counters under, e.g. "entslog".
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the expvar name,
+// and returns the updated `*Option` pointer.
+func WithExpvar(name string) Setting {
+	return func(option *Option) {
+		option.expvarName = name
+	}
+}
+
+// WithSchemaProfile selects the attribute naming convention of the emitted records, so
+// logs land correctly mapped in Elasticsearch (SchemaECS), Graylog (SchemaGELF) or
+// OpenTelemetry backends (SchemaOTel) without ingest pipelines. Attributes without a mapping keep their name. Renaming is
+// applied after filtering, so FilterAttrs still sees the default names.
+//
+// - `profile`: The naming convention, SchemaDefault keeps the names of this package.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the schema profile,
+// and returns the updated `*Option` pointer.
+func WithSchemaProfile(profile SchemaProfile) Setting {
+	return func(option *Option) {
+		option.profile = profile
+	}
+}
+
+// WithDBStats periodically logs the connection pool statistics (open connections, in use,
+// idle, wait count, wait duration, ...) when the underlying driver exposes its *sql.DB,
+// as entsql.Driver does. Reporting stops when the driver is closed.
+//
+// - `interval`: The interval between two reports, values <= 0 disable reporting.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the report interval,
+// and returns the updated `*Option` pointer.
+func WithDBStats(interval time.Duration) Setting {
+	return func(option *Option) {
+		option.dbStatsInterval = interval
+	}
+}
+
+// WithTickerlessTimers runs the background reporters of the driver, such as the pool
+// statistics of WithDBStats, on a single process-wide scheduler goroutine with coalesced
+// timers instead of one goroutine and ticker each.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the shared scheduler,
+// and returns the updated `*Option` pointer.
+func WithTickerlessTimers() Setting {
+	return WithScheduler(sharedScheduler)
+}
+
+// WithScheduler runs the background reporters of the driver on the given scheduler.
+// Tests can pass a NewManualScheduler and call Advance to trigger reports deterministically.
+//
+// - `scheduler`: The scheduler running the reporters.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the scheduler,
+// and returns the updated `*Option` pointer.
+func WithScheduler(scheduler *Scheduler) Setting {
+	return func(option *Option) {
+		option.scheduler = scheduler
+	}
+}
+
+// WithContextAudit enables a diagnostic mode that logs a warning, once per call site, when
+// an operation arrives with context.Background() or context.TODO(), i.e. without deadline,
+// cancellation or request values. The warning names the first caller outside ent and its
+// generated packages, helping to find code paths that drop the request context.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling the context audit,
+// and returns the updated `*Option` pointer.
+func WithContextAudit() Setting {
+	return func(option *Option) {
+		option.contextAudit = true
+	}
+}
+
+// WithPprofLabels runs every call into the underlying driver under pprof labels, as
+// pprof.Do does, with `sql_op` set to the SQL operation (SELECT, INSERT, COMMIT, ...)
+// and `table` set to the first table of the statement, so CPU profiles show which
+// queries dominate.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling profiler labels,
+// and returns the updated `*Option` pointer.
+func WithPprofLabels() Setting {
+	return func(option *Option) {
+		option.pprofLabels = true
+	}
+}
+
+// WithDatadogCorrelation adds `dd.trace_id` and `dd.span_id` attributes to every record,
+// so Datadog's log and trace correlation works for the SQL logs. The ids are taken from
+// the record context by extract, which defaults to DatadogIDsFromOTel when nil; users of
+// dd-trace-go can pass an extractor reading the Datadog span instead.
+//
+// - `extract`: The function extracting the ids, or nil to use the OpenTelemetry span.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the extractor,
+// and returns the updated `*Option` pointer.
+func WithDatadogCorrelation(extract TraceIDsFunc) Setting {
+	return func(option *Option) {
+		if extract == nil {
+			extract = DatadogIDsFromOTel
+		}
+		option.datadogIDs = extract
+	}
+}
+
+// WithResultSetSchemaLogging logs the column names, database types and scan types of the
+// result set of Query and QueryContext, once per query fingerprint. This gives context to
+// scan errors such as "sql: Scan error on column index 3" that ent surfaces without it.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling result set logging,
+// and returns the updated `*Option` pointer.
+func WithResultSetSchemaLogging() Setting {
+	return func(option *Option) {
+		option.resultSchema = true
+	}
+}
+
+// WithQueryFingerprint adds a `fingerprint` attribute to statement records: the query with
+// its literals, placeholders and IN-lists collapsed to `?` and its keywords upper-cased,
+// so that records of the same query with different parameters can be aggregated.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling query fingerprints,
+// and returns the updated `*Option` pointer.
+func WithQueryFingerprint() Setting {
+	return func(option *Option) {
+		option.fingerprints = true
+	}
+}
+
+// WithQueryHash adds a `query_hash` attribute to statement records: a 16 hex digit FNV-1a
+// hash of the query fingerprint, so dashboards can group records by query without storing
+// the full SQL text. The hash is stable across processes and releases of the same query.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling query hashes,
+// and returns the updated `*Option` pointer.
+func WithQueryHash() Setting {
+	return func(option *Option) {
+		option.queryHash = true
+	}
+}
+
+// WithCancellationPropagationCheck logs a warning when an operation returns more than grace
+// after its context was canceled, meaning the driver or the database does not actually stop
+// working on canceled statements. A grace of zero or less defaults to 100 milliseconds.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling the cancellation check,
+// and returns the updated `*Option` pointer.
+func WithCancellationPropagationCheck(grace time.Duration) Setting {
+	return func(option *Option) {
+		if grace <= 0 {
+			grace = 100 * time.Millisecond
+		}
+		option.cancelGrace = grace
+	}
+}
+
+// WithLevelFunc sets a function computing the level of the record reporting the outcome of
+// each operation from its Event: operation, statement class, tables, duration and error.
+// When set, the outcome of every operation is logged, carrying its duration, at the returned
+// level, instead of only errors at the error level and slow statements at warning level.
+// The level of the records logging statements before they run is not affected.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the level function,
+// and returns the updated `*Option` pointer.
+func WithLevelFunc(fn LevelFunc) Setting {
+	return func(option *Option) {
+		option.levelFunc = fn
+	}
+}
+
+// WithSessionTagging stores a request id in a session variable at the start of every
+// transaction, so server-side views show which request owns each connection, and logs
+// that it was set. On PostgreSQL `application_name` is set for the duration of the
+// transaction (see pg_stat_activity); on MySQL the `@request_id` user variable is set
+// (see performance_schema.user_variables_by_thread). Other dialects are left untouched.
+// The id is returned by fn, or is the transaction logging id when fn is nil.
+// A transaction whose session variable cannot be set is rolled back.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling session tagging,
+// and returns the updated `*Option` pointer.
+func WithSessionTagging(fn SessionTagFunc) Setting {
+	return func(option *Option) {
+		option.sessionTags = true
+		option.sessionTag = fn
+	}
+}
+
+// WithQueryNormalization collapses runs of whitespace and newlines in logged queries to a
+// single space, keeping multi-line statements of migrations and raw SQL on one line.
+// String literals are left untouched and the query sent to the database is not modified.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling query normalization,
+// and returns the updated `*Option` pointer.
+func WithQueryNormalization() Setting {
+	return func(option *Option) {
+		option.normalizeQuery = true
+	}
+}
+
+// WithPrettySQL is a development mode that reindents logged queries, one clause per line
+// and subqueries indented by depth, making generated ent queries readable. With color set,
+// keywords, literals and placeholders are colorized with ANSI escape sequences, which is
+// only meaningful when the records are written to a terminal. Multi-line values are best
+// shown by console handlers printing them verbatim; slog.TextHandler escapes newlines.
+// It takes precedence over WithQueryNormalization.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling pretty-printed queries,
+// and returns the updated `*Option` pointer.
+func WithPrettySQL(color bool) Setting {
+	return func(option *Option) {
+		option.pretty = true
+		option.prettyColor = color
+	}
+}
+
+// WithInterpolatedQuery is a debugging mode that additionally logs every statement with its
+// arguments substituted into the ? or $N placeholders, as a runnable statement to paste into
+// a SQL console. The record is logged at debug level with a `debug_only=true` attribute; the
+// interpolated statement is never sent to the database. Literal formatting is approximate
+// and follows the dialect for booleans and binary values. Statements touching sensitive
+// tables are not interpolated. Do not enable it in production, arguments are logged verbatim.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling interpolated queries,
+// and returns the updated `*Option` pointer.
+func WithInterpolatedQuery() Setting {
+	return func(option *Option) {
+		option.interpolate = true
+	}
+}
+
+// WithReportWriter periodically writes a human-readable plaintext report to w: a table of
+// the operations with their error rates and latencies, including transactions, commits and
+// rollbacks, followed by the top queries by total time. Each report covers the period since
+// the previous one, and a final report is written when the driver is closed. Pass an
+// *os.File to produce e.g. a daily digest file without standing up dashboards.
+//
+// - `w`: The writer receiving the reports, nil disables reporting.
+// - `interval`: The period covered by each report, values <= 0 disable reporting.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the report writer,
+// and returns the updated `*Option` pointer.
+func WithReportWriter(w io.Writer, interval time.Duration) Setting {
+	return func(option *Option) {
+		option.reportWriter = w
+		option.reportInterval = interval
+	}
+}
+
+// WithMaxQueryLength truncates logged queries longer than n bytes, such as huge generated
+// IN-clauses, and marks them with a trailing "...", a `query_truncated=true` attribute
+// and a `query_length` attribute holding the length of the original query.
+//
+// - `n`: The maximum length of logged queries in bytes, values <= 0 disable truncation.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the maximum query length,
+// and returns the updated `*Option` pointer.
+func WithMaxQueryLength(n int) Setting {
+	return func(option *Option) {
+		option.maxQueryLength = n
+	}
+}
+
+// WithExecResult reports the `rows_affected` and `last_insert_id` of the sql.Result of
+// Exec and ExecContext in the record logged when the statement completes. Values the
+// driver does not support, such as insert ids on PostgreSQL, are omitted.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling result logging,
+// and returns the updated `*Option` pointer.
+func WithExecResult() Setting {
+	return func(option *Option) {
+		option.execResult = true
+	}
+}
+
+// WithEventClock adds an `event_id` attribute to every record, and an ID to every Event,
+// combining a coarse millisecond timestamp with a process-wide atomic counter, e.g.
+// `1760425200000-0000000042`. The timestamp follows the monotonic clock, so ids keep
+// increasing across NTP corrections and VM pauses, and downstream systems can totally
+// order the records and events of one process by sorting their ids.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling event ids,
+// and returns the updated `*Option` pointer.
+func WithEventClock() Setting {
+	return func(option *Option) {
+		option.eventIDs = true
+	}
+}
+
+// WithStatsStore restores the statistics returned by SlogDriver.Stats from store when the
+// driver is created, saves them every interval and when the driver is closed, and keeps
+// them by query fingerprint as well, so that they survive process restarts. Percentiles
+// of restored statistics are approximate. See NewFileStatsStore for a file-based store.
+//
+// - `store`: The store of the statistics, nil disables persistence.
+// - `interval`: The interval between two saves, values <= 0 only save on Close.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the statistics store,
+// and returns the updated `*Option` pointer.
+func WithStatsStore(store StatsStore, interval time.Duration) Setting {
+	return func(option *Option) {
+		option.statsStore = store
+		option.statsInterval = interval
+	}
+}
+
+// WithScanErrorDetails wraps the result sets of Query to log the errors of Scan, such as
+// converting a NULL into a string, with the name, the index and the database type of the
+// column, the Go type of the destination and the type of the value returned by the
+// database driver, as far as they can be derived. QueryContext returns a *sql.Rows,
+// which cannot be wrapped, so its scan errors are not logged.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling scan error details,
+// and returns the updated `*Option` pointer.
+func WithScanErrorDetails() Setting {
+	return func(option *Option) {
+		option.scanErrors = true
+	}
+}
+
+// WithRowCounts wraps the result sets of Query, the method ent reads its results with, to
+// count the iterated rows and log the total with the iteration duration when the result set
+// is closed, along with any iteration or Close error. Result set sizes are critical for
+// diagnosing memory blowups. QueryContext returns a *sql.Rows, which cannot be wrapped,
+// so its result sets are not counted.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling row counts,
+// and returns the updated `*Option` pointer.
+func WithRowCounts() Setting {
+	return func(option *Option) {
+		option.rowCounts = true
+	}
+}
+
+// WithFirstSeenQueries logs an info record the first time a query fingerprint executes,
+// giving a change-detection signal when a deploy introduces new query shapes. The most
+// recently executed fingerprints are remembered in an LRU cache of the given size, so a
+// fingerprint evicted from it is reported again.
+//
+// - `size`: The number of fingerprints remembered, values <= 0 default to 1000.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling first-seen logging,
+// and returns the updated `*Option` pointer.
+func WithFirstSeenQueries(size int) Setting {
+	return func(option *Option) {
+		if size <= 0 {
+			size = maxTrackedQueries
+		}
+		option.firstSeen = size
+	}
+}
+
+// WithRowsLeakDetection logs a warning, with the query, its call site and its trace id,
+// when a result set returned by Query is garbage collected without Close having been
+// called, in which case its connection is released, or when it remains open longer
+// than maxOpen. Each result set is reported at most once. Like WithRowCounts, it does
+// not apply to the *sql.Rows returned by QueryContext.
+//
+// - `maxOpen`: The time after which a result set still open is reported, values <= 0 only report garbage collected result sets.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling leak detection,
+// and returns the updated `*Option` pointer.
+func WithRowsLeakDetection(maxOpen time.Duration) Setting {
+	return func(option *Option) {
+		option.leakCheck = true
+		option.leakAfter = max(maxOpen, 0)
+	}
+}
+
+// WithNPlusOneDetection logs a warning, with the fingerprint and the count, when the same
+// query fingerprint executes more than threshold times within one transaction, or within
+// one request context prepared with ContextWithQueryCounting, which usually indicates an
+// N+1 query pattern, e.g. loading edges in a loop instead of using eager loading.
+// The warning is logged once per fingerprint and scope.
+//
+// - `threshold`: The number of executions tolerated, values <= 0 disable the detection.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the N+1 threshold,
+// and returns the updated `*Option` pointer.
+func WithNPlusOneDetection(threshold int) Setting {
+	return func(option *Option) {
+		option.nPlusOne = threshold
+	}
+}
+
+// WithQueryBudget logs a warning when more than n statements execute under one request
+// context prepared with ContextWithQueryCounting, or contexts derived from it, catching
+// endpoints that silently issue hundreds of queries. The warning is logged once per
+// request and names the fingerprint of the statement exceeding the budget.
+//
+// - `n`: The number of statements tolerated per request, values <= 0 disable the budget.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the query budget,
+// and returns the updated `*Option` pointer.
+func WithQueryBudget(n int) Setting {
+	return func(option *Option) {
+		option.queryBudget = n
+	}
+}
+
+// WithSlowQueryExplain re-runs SELECT statements exceeding the slow threshold set by
+// WithSlowThreshold under EXPLAIN, or EXPLAIN QUERY PLAN on SQLite, and logs the plan
+// in a follow-up warning record carrying the fingerprint of the statement. Plans are
+// captured in the background through the underlying driver, outside of transactions,
+// and never for statements touching sensitive tables. EXPLAIN ANALYZE is never used,
+// so the statement is planned but not executed again, see WithExplainAnalyze.
+//
+// - `interval`: The minimum interval between two EXPLAIN statements, values <= 0 mean one minute.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling the capture of plans,
+// and returns the updated `*Option` pointer.
+func WithSlowQueryExplain(interval time.Duration) Setting {
+	return func(option *Option) {
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		option.explainInterval = interval
+	}
+}
+
+// WithNoticeAttribution tracks the running operations, so the notices routed to
+// SlogDriver.LogNotice while a single operation is running are logged with the query,
+// transaction and context attributes of that operation. Notices arriving while several
+// operations are running cannot be attributed and are logged with the driver attributes.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling the attribution of notices,
+// and returns the updated `*Option` pointer.
+func WithNoticeAttribution() Setting {
+	return func(option *Option) {
+		option.notices = true
+	}
+}
+
+// WithStatementSplitting logs each statement of a query made of several statements
+// separated by semicolons, as executed by migrations, in its own record following the
+// record of the query, with its statement_index and op attributes. The records of such
+// queries carry a statement_count attribute with or without this option.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling the splitting of multi-statement queries,
+// and returns the updated `*Option` pointer.
+func WithStatementSplitting() Setting {
+	return func(option *Option) {
+		option.splitLogging = true
+	}
+}
+
+// WithStatementOriginHash adds an origin_hash attribute to statement records, a stable
+// hash of the statement fingerprint and the package of the first caller outside ent and
+// its generated packages. Unlike file paths and line numbers, it does not change between
+// builds, so records aggregated across replicas running different builds can be grouped
+// by logical call site.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling origin hashes,
+// and returns the updated `*Option` pointer.
+func WithStatementOriginHash() Setting {
+	return func(option *Option) {
+		option.originHash = true
+	}
+}
+
+// WithoutArgs never logs the bind arguments of statements: the args attribute is omitted,
+// the values of mutation diffs are redacted and WithInterpolatedQuery is ignored.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by omitting the arguments,
+// and returns the updated `*Option` pointer.
+func WithoutArgs() Setting {
+	return func(option *Option) {
+		option.omitArgs = true
+	}
+}
+
+// WithVolumeSpikeDetection tracks the execution rate of each query fingerprint and logs
+// a warning, once per window, when the executions of a fingerprint within the current
+// window exceed factor times its baseline, a moving average of the previous windows.
+// This catches runaway loops and cache regressions from within the data layer. Spikes
+// are reported after three windows of history and ten executions within the window.
+//
+// - `factor`: The ratio between the rate and the baseline above which a warning is logged, values <= 1 mean 5.
+// - `window`: The period over which executions are counted, values <= 0 mean one minute.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling the detection of volume spikes,
+// and returns the updated `*Option` pointer.
+func WithVolumeSpikeDetection(factor float64, window time.Duration) Setting {
+	return func(option *Option) {
+		if factor <= 1 {
+			factor = 5
+		}
+		if window <= 0 {
+			window = time.Minute
+		}
+		option.volumeFactor, option.volumeWindow = factor, window
+	}
+}
+
+// WithQueryHistory keeps the last size statements of the driver and its transactions in
+// memory, returned by SlogDriver.History, e.g. to show the recent queries of a service on
+// a debug page. The query texts are held by store, which keeps a single copy of the text
+// of the statements executed many times, so a large history of long generated queries
+// stays small. Statements touching sensitive tables have their literals scrubbed.
+//
+// - `size`: The number of statements kept, values <= 0 disable the history.
+// - `store`: The store of the query texts, nil uses NewQueryStore. A store compressing the texts can be plugged in.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling the query history,
+// and returns the updated `*Option` pointer.
+func WithQueryHistory(size int, store QueryStore) Setting {
+	return func(option *Option) {
+		option.historySize, option.queryStore = size, store
+	}
+}
+
+// WithArgFilter passes each bind argument through fn before it is logged, so individual
+// values such as passwords and tokens can be masked while the others remain visible. It
+// also applies to WithInterpolatedQuery; the arguments of statements touching sensitive
+// tables are masked regardless.
+//
+// - `fn`: A function returning the value logged for the argument at index.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the argument filter,
+// and returns the updated `*Option` pointer.
+func WithArgFilter(fn ArgFilterFunc) Setting {
+	return func(option *Option) {
+		option.argFilter = fn
+	}
+}
+
+// WithClassifier masks the bind arguments labeled by c, logging them as [REDACTED:label],
+// e.g. [REDACTED:email]. Classifiers are given the column each argument is bound to when
+// it can be derived from the statement. DefaultClassifier returns a regex-based classifier
+// for common personal data; classification happens before the filter of WithArgFilter.
+//
+// - `c`: The classifier labeling the sensitive arguments.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the classifier,
+// and returns the updated `*Option` pointer.
+func WithClassifier(c Classifier) Setting {
+	return func(option *Option) {
+		option.classifier = c
+	}
+}
+
+// WithQueryFilter passes the text of each logged query through fn, so the literals it may
+// contain, such as email addresses, can be masked. It applies after the literals of queries
+// touching sensitive tables are scrubbed and before pretty-printing, normalization and
+// truncation. The entredact package provides a regex-based filter.
+//
+// - `fn`: A function returning the logged text of a query.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the query filter,
+// and returns the updated `*Option` pointer.
+func WithQueryFilter(fn QueryFilterFunc) Setting {
+	return func(option *Option) {
+		option.queryFilter = fn
+	}
+}
+
+// WithMaxConcurrentLoggedTx caps the number of concurrent transactions whose statements
+// are logged, so logging overhead degrades gracefully under load. The statements of the
+// other transactions are not logged; instead, a summary record with their number, errors
+// and the duration of the transaction is logged on commit or rollback. Errors, slow
+// statements and other warnings are logged regardless. While more transactions than the
+// cap are open, the logged ones are chosen deterministically by the hash of their id.
+//
+// - `n`: The maximum number of concurrently logged transactions, values <= 0 disable the cap.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the cap,
+// and returns the updated `*Option` pointer.
+func WithMaxConcurrentLoggedTx(n int) Setting {
+	return func(option *Option) {
+		option.maxLoggedTx = n
+	}
+}
+
+// WithArgSummary logs a summary of the byte slice and string arguments longer than size
+// bytes instead of their content, such as `bytes(len=10240, sha256=9f86d081…)`, giving
+// their length and the first bytes of their SHA-256 hash, so blob inserts do not
+// produce megabyte records. Summaries are computed after any WithArgFilter.
+//
+// - `size`: The length above which arguments are summarized, values <= 0 disable summaries.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the summary size,
+// and returns the updated `*Option` pointer.
+func WithArgSummary(size int) Setting {
+	return func(option *Option) {
+		option.maxArgSize = size
+	}
+}
+
+// WithTxReadOnlyDowngradeDetection logs a warning, once per transaction, when an INSERT,
+// UPDATE, DELETE or DDL statement succeeds in a transaction begun by BeginTx with the
+// ReadOnly option, meaning the driver, the database or a proxy silently ignored it. This
+// guards the assumptions made about read-only transactions being routed to replicas.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling the detection,
+// and returns the updated `*Option` pointer.
+func WithTxReadOnlyDowngradeDetection() Setting {
+	return func(option *Option) {
+		option.readOnlyCheck = true
+	}
+}
+
+// WithMaxArgsSize caps the formatted size of the args attribute of each record. Arguments
+// are kept while they fit, the first argument that does not fit is cut and marked with
+// "...", and the record carries `args_truncated=true` and the `args_count` of the statement.
+// The cap applies to the arguments as logged, after WithArgFilter and WithArgSummary.
+//
+// - `bytes`: The maximum formatted size of the arguments, values <= 0 disable the cap.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the size cap,
+// and returns the updated `*Option` pointer.
+func WithMaxArgsSize(bytes int) Setting {
+	return func(option *Option) {
+		option.maxArgsSize = bytes
+	}
+}
+
+// WithAuditSink writes a mutation audit trail to w: one JSON AuditEvent per line for each
+// successful INSERT, UPDATE and DELETE statement, with the diffs supplied by WithMutationDiff,
+// and for the commit or rollback of the transactions that recorded mutations. Each entry
+// includes the hash of the previous one, so tampering with the trail is detected by
+// VerifyAuditChain. Each driver starts a new chain, which VerifyAuditChain only accepts
+// after the first line when told the sink is shared by several drivers. Queries and diffs are redacted like the records of the driver.
+//
+// - `w`: The writer receiving the audit trail, e.g. an append-only file.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the audit sink,
+// and returns the updated `*Option` pointer.
+func WithAuditSink(w io.Writer) Setting {
+	return func(option *Option) {
+		option.auditSink = w
+	}
+}
+
+// WithHashedArgs replaces each bind argument with a salted hash, such as
+// `hash:3f2a9c0d1e8b7a65`, so records sharing a value can be correlated, e.g. to follow
+// the queries of a user, without disclosing it. Equal values hash equally whatever their
+// Go type. Mutation diffs are hashed likewise. The hash is an HMAC-SHA256 keyed by salt,
+// which must be kept secret to prevent guessing low-entropy values. Arguments masked by
+// WithSensitiveColumnList or WithClassifier remain masked, and WithArgSummary and
+// WithInterpolatedQuery are ignored.
+//
+// - `salt`: The secret key of the hashes, shared by the services whose records are correlated.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the hash salt,
+// and returns the updated `*Option` pointer.
+func WithHashedArgs(salt []byte) Setting {
+	return func(option *Option) {
+		option.argSalt = salt
+	}
+}
+
+// Development is the preset for local development: logged queries are reindented and
+// also logged with their arguments substituted, and WithExplainAnalyze is available.
+// Settings following the preset override it.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by applying the development preset,
+// and returns the updated `*Option` pointer.
+func Development() Setting {
+	return func(option *Option) {
+		option.development = true
+		option.pretty = true
+		option.interpolate = true
+	}
+}
+
+// Production is the preset for deployed services. It reverts the Development preset and
+// disables WithExplainAnalyze, so statements are never executed twice, even when the
+// setting is applied after the preset.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by applying the production preset,
+// and returns the updated `*Option` pointer.
+func Production() Setting {
+	return func(option *Option) {
+		option.development = false
+		option.pretty = false
+		option.prettyColor = false
+		option.interpolate = false
+		option.analyzeAfter = 0
+	}
+}
+
+// WithExplainAnalyze captures the plans of the SELECT statements taking longer than
+// threshold with EXPLAIN ANALYZE, on Postgres and MySQL, and logs them with their actual
+// timings in a follow-up record. As EXPLAIN ANALYZE executes the statement again, it is
+// only available with the Development preset, and ignored with a warning otherwise. The
+// statement runs in the background through the underlying driver, in a read-only
+// transaction that is always rolled back, and is canceled after twice the duration of the
+// original execution. Statements that write, lock rows or touch sensitive tables are never
+// analyzed, and at most one statement is analyzed per second.
+//
+// - `threshold`: The duration above which statements are analyzed, values <= 0 mean 100ms.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling EXPLAIN ANALYZE,
+// and returns the updated `*Option` pointer.
+func WithExplainAnalyze(threshold time.Duration) Setting {
+	return func(option *Option) {
+		if threshold <= 0 {
+			threshold = 100 * time.Millisecond
+		}
+		option.analyzeAfter = threshold
+	}
+}
+
+// WithSanitizers passes the bind arguments through sanitizers, in order, each receiving
+// the result of the previous one, so the personal data they contain is masked before
+// logging. DefaultSanitizer masks email addresses, phone numbers and card numbers passing
+// the Luhn check. Unlike WithClassifier, which masks whole arguments, detectors only mask
+// the matching parts of strings. Sanitizers run after WithSensitiveColumnList and
+// WithClassifier, and before WithArgFilter. Calls are cumulative.
+//
+// - `sanitizers`: The sanitizers, e.g. entslog.DefaultSanitizer() or a custom Detector.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by adding the sanitizers,
+// and returns the updated `*Option` pointer.
+func WithSanitizers(sanitizers ...Sanitizer) Setting {
+	return func(option *Option) {
+		option.sanitizers = append(option.sanitizers, sanitizers...)
+	}
+}
+
+// WithRecordChain makes the records of the driver tamper-evident: every record carries a
+// `chain` group with the id of the driver instance, a sequence number, the hash of the
+// previous record and its own hash, a SHA-256 over its level, message, attributes and the
+// previous hash. VerifyRecordChain checks the output of slog.JSONHandler, detecting altered,
+// inserted and removed records. The time is set by the logger and not covered. Records are
+// hashed as the driver emits them, so the handler must not rename or rewrite attributes,
+// and records dropped by an asynchronous queue break the chain. Use WithAuditSink for a
+// dedicated trail of the mutations instead.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by enabling the record chain,
+// and returns the updated `*Option` pointer.
+func WithRecordChain() Setting {
+	return func(option *Option) {
+		option.recordChain = true
+	}
+}
+
+// WithAttrs adds attributes to every record of the driver and its transactions, such as
+// the service name, the database name or the environment, without deriving the logger
+// with slog.Logger.With. They precede the attributes of the records and, unlike logger
+// attributes, are seen by WithFilter. Calls are cumulative.
+//
+// - `attrs`: The attributes, e.g. slog.String("service", "billing").
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by adding the attributes,
+// and returns the updated `*Option` pointer.
+func WithAttrs(attrs ...slog.Attr) Setting {
+	return func(option *Option) {
+		option.attrs = append(option.attrs, attrs...)
+	}
+}
+
+// WithGroup nests the attributes of every record, such as query, args and id, under a
+// single group, so they cannot collide with the attributes of the application when
+// handlers flatten records, e.g. db.query with the text handler. The chain group of
+// WithRecordChain remains at the top level.
+//
+// - `name`: The name of the group, an empty name means "db".
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the attribute group,
+// and returns the updated `*Option` pointer.
+func WithGroup(name string) Setting {
+	return func(option *Option) {
+		if name == "" {
+			name = "db"
+		}
+		option.group = name
+	}
+}
+
+// WithSemConv names the attributes of the records after the OpenTelemetry database
+// semantic conventions, so logs ingest cleanly into OpenTelemetry backends: every record
+// carries `db.system`, and `query`, `op` and `tables` become `db.statement`, `db.operation`
+// and `db.sql.table`, the primary table of the statement. It is a shorthand for
+// WithSchemaProfile(SchemaOTel).
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by selecting the semantic conventions,
+// and returns the updated `*Option` pointer.
+func WithSemConv() Setting {
+	return WithSchemaProfile(SchemaOTel)
+}
+
+// WithErrorLevelFunc computes the level of the records logging errors from the error, e.g.
+// Debug for context.Canceled, Warn for constraint violations and Error for connection
+// failures, instead of the single level of WithErrorLevel, which is used when fn returns nil.
+//
+//	entslog.WithErrorLevelFunc(func(err error) slog.Leveler {
+//		if errors.Is(err, context.Canceled) {
+//			return slog.LevelDebug
+//		}
+//		return nil
+//	})
+//
+// - `fn`: The function returning the level of the record logging an error.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the error level function
+// and enabling error handling, then returns the updated `*Option` pointer.
+func WithErrorLevelFunc(fn ErrorLevelFunc) Setting {
+	return func(option *Option) {
+		option.errLevelFunc = fn
+		option.handleError = true
+	}
+}
+
+// WithBenignErrors adds functions matching errors that are part of the normal control
+// flow, which are logged at Debug level instead of the error level. By default IsNotFound
+// matches sql.ErrNoRows and ent's NotFoundError. WithErrorLevelFunc takes precedence.
+// Calls are cumulative.
+//
+// - `fns`: The functions reporting whether an error is benign.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by adding the benign error functions,
+// and returns the updated `*Option` pointer.
+func WithBenignErrors(fns ...BenignFunc) Setting {
+	return func(option *Option) {
+		option.benign = append(slices.Clip(option.benign), fns...)
+	}
+}
+
+// WithoutBenignErrors logs every error at the error level, including not found errors.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by removing the benign error functions,
+// and returns the updated `*Option` pointer.
+func WithoutBenignErrors() Setting {
+	return func(option *Option) {
+		option.benign = nil
+	}
+}
+
+// WithErrorFilter selects the errors that are logged: the errors for which fn returns false,
+// such as context.Canceled during a graceful shutdown, are not logged at all, while still
+// being returned to the caller.
+//
+//	entslog.WithErrorFilter(func(ctx context.Context, err error) bool {
+//		return !errors.Is(err, context.Canceled)
+//	})
+//
+// - `fn`: The function reporting whether an error is logged.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the error filter,
+// and returns the updated `*Option` pointer.
+func WithErrorFilter(fn ErrorFilterFunc) Setting {
+	return func(option *Option) {
+		option.errFilter = fn
+	}
+}
+
+// WithoutTxLogging suppresses the "Tx started", "BeginTx started", "Commit" and "Rollback"
+// records, while the statements inside transactions are still logged, carrying the
+// transaction id. Failed and slow commits and rollbacks are still logged.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by disabling the transaction lifecycle records,
+// and returns the updated `*Option` pointer.
+func WithoutTxLogging() Setting {
+	return func(option *Option) {
+		option.noTxLogs = true
+	}
+}
+
+// WithoutDialectAttr removes the `dialect` attribute every record carries by default,
+// naming the dialect of the underlying driver, such as mysql, postgres or sqlite3, which
+// tells apart the records of applications talking to several databases. With
+// WithSemConv, records carry `db.system` instead.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by disabling the dialect attribute,
+// and returns the updated `*Option` pointer.
+func WithoutDialectAttr() Setting {
+	return func(option *Option) {
+		option.noDialect = true
+	}
+}
+
+// opSet selects operations by name.
+type opSet struct {
+	include map[string]bool // operations logged, nil includes every operation.
+	exclude map[string]bool // operations not logged.
+}
+
+func (s *opSet) allows(name string) bool {
+	return (s.include == nil || s.include[name]) && !s.exclude[name]
+}
+
+func addOps(set map[string]bool, ops []string) map[string]bool {
+	if set == nil {
+		set = make(map[string]bool, len(ops))
+	}
+	for _, op := range ops {
+		set[op] = true
+	}
+	return set
+}
+
+// WithOperations only logs the records of the given operations, e.g. Exec and Commit but
+// not Query. The errors, slow statements and warnings of the other operations are still
+// logged. Calls are cumulative.
+//
+// - `ops`: The operation names: Exec, ExecContext, Query, QueryContext, Tx, BeginTx, Commit or Rollback.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by adding the logged operations,
+// and returns the updated `*Option` pointer.
+func WithOperations(ops ...string) Setting {
+	return func(option *Option) {
+		if option.ops == nil {
+			option.ops = new(opSet)
+		}
+		option.ops.include = addOps(option.ops.include, ops)
+	}
+}
+
+// WithoutOperations does not log the records of the given operations, e.g. Query. Their
+// errors, slow statements and warnings are still logged. It takes precedence over
+// WithOperations. Calls are cumulative.
+//
+// - `ops`: The operation names: Exec, ExecContext, Query, QueryContext, Tx, BeginTx, Commit or Rollback.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by adding the excluded operations,
+// and returns the updated `*Option` pointer.
+func WithoutOperations(ops ...string) Setting {
+	return func(option *Option) {
+		if option.ops == nil {
+			option.ops = new(opSet)
+		}
+		option.ops.exclude = addOps(option.ops.exclude, ops)
+	}
+}
+
+// WithIgnoreQueries does not log the statements matching one of the glob patterns, such
+// as health checks and schema introspection queries. Patterns match whole queries, case
+// insensitively and with their whitespace collapsed, * matching any text and ? any single
+// character, e.g. "SELECT 1" or "SELECT * FROM information_schema.*". Errors, slow
+// statements and warnings are still logged. Calls are cumulative.
+//
+// - `patterns`: The glob patterns of the ignored queries.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by adding the ignored query patterns,
+// and returns the updated `*Option` pointer.
+func WithIgnoreQueries(patterns ...string) Setting {
+	return func(option *Option) {
+		for _, pattern := range patterns {
+			option.ignored = append(option.ignored, globPattern(pattern))
+		}
+	}
+}
+
+// WithIgnoreQueryPatterns is like WithIgnoreQueries with regular expressions, which match
+// anywhere in the queries, once their whitespace is collapsed, e.g. `(?i)\bpg_catalog\.`.
+//
+// - `patterns`: The regular expressions of the ignored queries.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by adding the ignored query patterns,
+// and returns the updated `*Option` pointer.
+func WithIgnoreQueryPatterns(patterns ...*regexp.Regexp) Setting {
+	return func(option *Option) {
+		option.ignored = append(option.ignored, patterns...)
+	}
+}
+
+// WithMessageFunc rewrites the message of every record, such as "Exec", "Query done",
+// "Tx started", "Commit" or "Rollback", e.g. to follow the event naming conventions of an
+// organization or to localize them.
+//
+// - `fn`: The function returning the logged message.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the message function,
+// and returns the updated `*Option` pointer.
+func WithMessageFunc(fn MessageFunc) Setting {
+	return func(option *Option) {
+		option.messageFunc = fn
+	}
+}
+
+// WithMessages replaces the messages of the records found in messages, e.g.
+// {"Exec": "db.exec", "Tx started": "db.tx.begin"}, others are kept. It replaces the
+// function set by WithMessageFunc.
+//
+// - `messages`: The logged messages by message of the driver.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the message function,
+// and returns the updated `*Option` pointer.
+func WithMessages(messages map[string]string) Setting {
+	messages = maps.Clone(messages)
+	return WithMessageFunc(func(msg string) string {
+		if m, ok := messages[msg]; ok {
+			return m
+		}
+		return msg
+	})
+}
+
+// WithMessagePrefix prefixes the message of every record, e.g. "ent: " gives "ent: Exec",
+// so the records of the driver are easily filtered in shared logs. The prefix is added to
+// the messages rewritten by WithMessages and WithMessageFunc.
+//
+// - `prefix`: The prefix of the messages, including any separator.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the message prefix,
+// and returns the updated `*Option` pointer.
+func WithMessagePrefix(prefix string) Setting {
+	return func(option *Option) {
+		option.msgPrefix = prefix
+	}
+}
+
+// WithClock sets the clock the driver measures durations with, so tests of the driver and
+// of applications embedding it can assert on deterministic duration attributes and events.
+//
+//	now := time.Unix(0, 0)
+//	drv := entslog.New(drv, entslog.WithClock(func() time.Time {
+//		now = now.Add(time.Millisecond)
+//		return now
+//	}))
+//
+// - `now`: The function returning the current time, safe for concurrent use, nil means time.Now.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the clock,
+// and returns the updated `*Option` pointer.
+func WithClock(now func() time.Time) Setting {
+	return func(option *Option) {
+		option.clock = now
+	}
+}
+
+// WithContextAttrs adds the attributes returned by fn for the context of every record,
+// so values such as the tenant or user id flow from the context into the records of the
+// statements. Calls are cumulative, the attributes of the functions follow each other in
+// order, after the attributes of WithAttrs and before those of the record.
+//
+// - `fn`: The function returning the attributes of a context, safe for concurrent use.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by adding the context attributes function,
+// and returns the updated `*Option` pointer.
+func WithContextAttrs(fn ContextAttrsFunc) Setting {
+	return func(option *Option) {
+		if fn == nil {
+			return
+		}
+		if prev := option.ctxAttrs; prev != nil {
+			option.ctxAttrs = func(ctx context.Context) []slog.Attr {
+				return append(slices.Clip(prev(ctx)), fn(ctx)...)
+			}
+			return
+		}
+		option.ctxAttrs = fn
+	}
+}
+
+// WithMigrationLevel sets the default level of the records of DDL statements, such as those
+// of ent's schema.Create, overriding WithDefaultLevel and WithLevelFor, so migration
+// activity can be audited separately. The records of DDL statements always carry the
+// `phase=migration` and `ddl=true` attributes. Errors, slow statements and the levels
+// computed by WithLevelFunc are not affected.
+//
+// - `level`: The default level of the records of DDL statements.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the migration level,
+// and returns the updated `*Option` pointer.
+func WithMigrationLevel(level slog.Leveler) Setting {
+	return func(option *Option) {
+		option.ddlLevel = level
+	}
+}
+
+// WithDeprecatedDriverMethodBridging bridges the context methods ent probes for when the
+// underlying driver lacks them, as the drivers of older ent releases and third-party
+// drivers written against them do, so one entslog version serves an ent upgrade window:
+// QueryContext runs on the *sql.DB returned by the `DB() *sql.DB` method of the driver,
+// BeginTx runs Tx when no options are given and begins the transaction on that *sql.DB
+// otherwise, and the QueryContext of transactions is looked up through their
+// `Unwrap() dialect.Tx` chain. Without bridging, or when no fallback is found, the
+// methods fail with ErrUnsupported.
+//
+// - `enabled`: Whether the methods are bridged, the default is true in builds tagged entslog_legacy_ent and false otherwise.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the method bridging,
+// and returns the updated `*Option` pointer.
+func WithDeprecatedDriverMethodBridging(enabled bool) Setting {
+	return func(option *Option) {
+		option.bridgeMethods = enabled
+	}
+}
+
+// levelMap maps operation names to their default level.
+type levelMap map[string]slog.Leveler
+
+// WithLevelFor sets the default level of the records of an operation, overriding
+// WithDefaultLevel, e.g. to log Query at Debug and Exec, Commit and Rollback at Info.
+// Errors, slow statements and the levels computed by WithLevelFunc are not affected.
+// Calls are cumulative, a later call for the same operation replaces the level.
+//
+// - `op`: The operation name: Exec, ExecContext, Query, QueryContext, Tx, BeginTx, Commit or Rollback.
+// - `level`: The default level of its records, nil keeps the default level and is reported by NewWithError.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by setting the level of the operation,
+// and returns the updated `*Option` pointer.
+func WithLevelFor(op string, level slog.Leveler) Setting {
+	return func(option *Option) {
+		if option.opLevels == nil {
+			option.opLevels = make(levelMap)
+		}
+		option.opLevels[op] = level
+	}
+}
+
+// Group combines settings into one, applied in order, so a standard logging policy can
+// be defined and shared as a single value, and extended with service-specific settings:
+//
+//	var Policy = entslog.Group(entslog.Production(), entslog.WithSensitiveTableList("users"))
+//	drv := entslog.New(drv, Policy, entslog.WithSlowThreshold(time.Second))
+//
+// - `ss`: The settings to combine, nil ones are skipped.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by applying each setting,
+// and returns the updated `*Option` pointer.
+func Group(ss ...Setting) Setting {
+	return func(option *Option) {
+		for _, s := range ss {
+			if s != nil {
+				s(option)
+			}
+		}
+	}
+}
+
+// NewOption returns the default options with settings applied, to be passed to WithOptions.
+func NewOption(ss ...Setting) Option {
+	o := defaultOption
+	return *settings.Apply(&o, ss)
+}
+
+// WithOptions replaces all the options with a copy of o, built by NewOption, so a standard
+// logging policy can be shared as a value. Settings following it override its options, and
+// drivers configured with the same value do not share state such as SLO windows.
+//
+// - `o`: The options to use.
+//
+// Returns a function that accepts an `*Option` parameter, modifies it by replacing all its options,
+// and returns the updated `*Option` pointer.
+func WithOptions(o Option) Setting {
+	return func(option *Option) {
+		*option = o
+		option.sensitiveTables = maps.Clone(o.sensitiveTables)
+		option.sensitiveCols = maps.Clone(o.sensitiveCols)
+		option.observers = slices.Clone(o.observers)
+		option.sanitizers = slices.Clone(o.sanitizers)
+		option.attrs = slices.Clone(o.attrs)
+		option.opLevels = maps.Clone(o.opLevels)
+		option.benign = slices.Clone(o.benign)
+		option.ignored = slices.Clone(o.ignored)
+		if o.ops != nil {
+			option.ops = &opSet{include: maps.Clone(o.ops.include), exclude: maps.Clone(o.ops.exclude)}
+		}
+		if o.slos != nil {
+			option.slos = o.slos.clone()
+		}
+	}
+}
+
 // make configures and returns a new logging handler based on the provided options.
@@ -0,0 +1,60 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+
+	"entgo.io/ent/dialect"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// dbSystem maps an ent dialect name to the OpenTelemetry db.system attribute.
+func dbSystem(name string) attribute.KeyValue {
+	switch name {
+	case dialect.MySQL:
+		return semconv.DBSystemMySQL
+	case dialect.Postgres:
+		return semconv.DBSystemPostgreSQL
+	case dialect.SQLite:
+		return semconv.DBSystemSqlite
+	}
+	return semconv.DBSystemKey.String(name)
+}
+
+// startSpan starts a client span for the operation as a child of the span in ctx.
+func (h *Handler) startSpan(ctx context.Context, name, query string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{dbSystem(h.dialect)}
+	if query != "" {
+		attrs = append(attrs, semconv.DBStatementKey.String(query))
+	}
+	return h.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endSpan records err on span, if any, and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceOTel is a TraceFunc that reuses the OpenTelemetry span found in the context,
+// so SQL log records can be joined with distributed traces. The id is formed as
+// "<trace-id>-<span-id>"; when the context carries no valid span context it falls back
+// to the default trace function, an id derived from the request id of EnsureRequestID
+// or a random UUID.
+//
+//	drv := entslog.New(drv, entslog.WithTrace(entslog.TraceOTel))
+func TraceOTel(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return traceUUID(ctx)
+	}
+	return sc.TraceID().String() + "-" + sc.SpanID().String()
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// otelMetrics is an Observer recording operations with OpenTelemetry metric instruments.
+type otelMetrics struct {
+	system   attribute.KeyValue
+	duration metric.Float64Histogram
+	errors   metric.Int64Counter
+}
+
+// newOTelMetrics creates the instruments on meter. Instrument errors are reported
+// through the global OpenTelemetry error handler, as the OpenTelemetry API does.
+func newOTelMetrics(meter metric.Meter, dialect string) *otelMetrics {
+	duration, err := meter.Float64Histogram("db.client.operation.duration",
+		metric.WithDescription("Duration of database client operations."),
+		metric.WithUnit("s"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	errors, err := meter.Int64Counter("db.client.operation.errors",
+		metric.WithDescription("Number of failed database client operations."),
+		metric.WithUnit("{error}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	return &otelMetrics{system: dbSystem(dialect), duration: duration, errors: errors}
+}
+
+// Observe implements Observer.
+func (m *otelMetrics) Observe(ctx context.Context, e Event) {
+	attrs := metric.WithAttributes(m.system, semconv.DBOperationKey.String(dbOperation(e)))
+	m.duration.Record(ctx, e.Duration.Seconds(), attrs)
+	if e.Err != nil {
+		m.errors.Add(ctx, 1, attrs)
+	}
+}
+
+// dbOperation returns the db.operation value of an event: the leading keyword of the
+// statement, or the SQL equivalent of a transaction control operation.
+func dbOperation(e Event) string {
+	if e.Query != "" {
+		// The fingerprint starts with the leading keyword in upper case, after the opening
+		// parentheses, and without the whitespace and comments of the statement.
+		word, _, _ := strings.Cut(strings.TrimLeft(e.Fingerprint, "( "), " ")
+		if word == "" || !isWord(word[0]) || isDigit(word[0]) {
+			return ""
+		}
+		return word
+	}
+	switch e.Op {
+	case "Tx", "BeginTx":
+		return "BEGIN"
+	case "Commit":
+		return "COMMIT"
+	case "Rollback":
+		return "ROLLBACK"
+	}
+	return e.Op
+}
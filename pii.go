@@ -0,0 +1,214 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Classifier labels the personal or secret data found in bind arguments, such as an
+	// email address or a card number. Labeled arguments are masked in the records, which
+	// carry the label instead of the value.
+	Classifier interface {
+		// Classify returns the label of value, or an empty string when it is not sensitive.
+		// column is the column the argument is bound to when it can be derived from the
+		// statement, as in `email = ?` or the column list of an INSERT, or empty otherwise.
+		Classify(ctx context.Context, column string, value any) string
+	}
+	// PIIRule labels the values matching Value, or bound to a column matching Column.
+	// A rule with both patterns labels the values matching either.
+	PIIRule struct {
+		Label  string                  // Label is the label of the matching values, e.g. email.
+		Column *regexp.Regexp          // Column matches the names of the columns holding sensitive values, optional.
+		Value  *regexp.Regexp          // Value matches the sensitive string values, optional.
+		Valid  func(match string) bool // Valid confirms the matches of Value, optional, e.g. a checksum.
+	}
+	// RegexClassifier is a Classifier applying its rules in order, the first matching rule
+	// labels the value.
+	RegexClassifier []PIIRule
+)
+
+// DefaultClassifier returns a RegexClassifier labeling email addresses, card numbers
+// passing the Luhn check, phone numbers and the values of columns named like passwords,
+// secrets and tokens. Column names must end with the whole word, optionally followed by
+// _hash or _digest, e.g. password, access_token or api_key_hash, but not token_count.
+func DefaultClassifier() RegexClassifier {
+	return RegexClassifier{
+		{Label: "secret", Column: regexp.MustCompile(`(?i)(^|_)(password|passwd|secret|token|api_?key)(_hash|_digest)?$`)},
+		{Label: "email", Column: regexp.MustCompile(`(?i)e_?mail`), Value: emailPattern},
+		{Label: "card_number", Value: cardNumberPattern, Valid: luhn},
+		{Label: "phone", Column: regexp.MustCompile(`(?i)(phone|mobile)`), Value: regexp.MustCompile(`^\+?\d{1,3}[ .-]?\(?\d{2,4}\)?[ .-]?\d{3,4}[ .-]?\d{3,4}$`)},
+	}
+}
+
+// Classify implements Classifier. Only strings, byte slices and fmt.Stringer values are
+// matched against the value patterns.
+func (c RegexClassifier) Classify(_ context.Context, column string, value any) string {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	case fmt.Stringer:
+		text = v.String()
+	}
+	for _, rule := range c {
+		if rule.Column != nil && column != "" && rule.Column.MatchString(column) {
+			return rule.Label
+		}
+		if rule.Value != nil && text != "" && rule.matches(text) {
+			return rule.Label
+		}
+	}
+	return ""
+}
+
+// matches reports whether text contains a match of the value pattern confirmed by Valid.
+func (r *PIIRule) matches(text string) bool {
+	if r.Valid == nil {
+		return r.Value.MatchString(text)
+	}
+	for _, match := range r.Value.FindAllString(text, -1) {
+		if r.Valid(match) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifiedValue replaces the values labeled by a Classifier.
+func classifiedValue(label string) string {
+	return "[REDACTED:" + label + "]"
+}
+
+// argColumns returns the columns the placeholders of a query, given its significant
+// tokens, are bound to, by argument index, as far as they can be derived from
+// comparisons, IN lists and INSERT column lists.
+func argColumns(tokens []token) map[int]string {
+	columns := make(map[int]string)
+	inserted := insertColumns(tokens)
+	next, values, depth, slot := 0, false, 0, 0
+	for i, t := range tokens {
+		if inserted != nil {
+			// Track the position within the row constructors of the VALUES clause.
+			switch {
+			case depth == 0 && t.kind == tokWord:
+				values = strings.EqualFold(t.text, "VALUES")
+			case values && t.text == "(":
+				if depth == 0 {
+					slot = 0
+				}
+				depth++
+			case values && t.text == ")" && depth > 0:
+				depth--
+			case values && t.text == "," && depth == 1:
+				slot++
+			}
+		}
+		if t.kind != tokParam {
+			continue
+		}
+		index := next
+		if t.text[0] == '$' {
+			n, err := strconv.Atoi(t.text[1:])
+			if err != nil {
+				continue
+			}
+			index = n - 1
+		} else {
+			next++
+		}
+		if depth > 0 && slot < len(inserted) {
+			columns[index] = inserted[slot]
+			continue
+		}
+		if column := comparedColumn(tokens, i); column != "" {
+			columns[index] = column
+		}
+	}
+	return columns
+}
+
+// insertColumns returns the column list of an INSERT statement, or nil.
+func insertColumns(tokens []token) []string {
+	if len(tokens) == 0 || !strings.EqualFold(tokens[0].text, "INSERT") {
+		return nil
+	}
+	start := -1
+	for i, t := range tokens {
+		if strings.EqualFold(t.text, "VALUES") {
+			return nil
+		}
+		if t.text == "(" {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+	var columns []string
+	for _, t := range tokens[start:] {
+		switch {
+		case t.text == ")":
+			return columns
+		case t.kind == tokWord || t.kind == tokQuoted:
+			columns = append(columns, unquote(t))
+		}
+	}
+	return nil
+}
+
+// comparedColumn returns the column compared to the placeholder at tokens[i], as in
+// `email = ?`, `t.email LIKE ?` or `email IN (?, ?)`, or an empty string.
+func comparedColumn(tokens []token, i int) string {
+	j := i - 1
+	// Skip the other members of an IN list.
+	for j >= 0 && (tokens[j].kind == tokParam || tokens[j].text == ",") {
+		j--
+	}
+	if j >= 1 && tokens[j].text == "(" && strings.EqualFold(tokens[j-1].text, "IN") {
+		j -= 2
+		if j >= 0 && strings.EqualFold(tokens[j].text, "NOT") {
+			j--
+		}
+	} else {
+		j = i - 1
+		for j >= 0 && tokens[j].kind == tokPunct && strings.Contains("=<>!", tokens[j].text) {
+			j--
+		}
+		if j == i-1 && j >= 0 && tokens[j].kind == tokWord {
+			switch strings.ToUpper(tokens[j].text) {
+			case "LIKE", "ILIKE":
+				j--
+				if j >= 0 && strings.EqualFold(tokens[j].text, "NOT") {
+					j--
+				}
+			default:
+				return ""
+			}
+		} else if j == i-1 {
+			return ""
+		}
+	}
+	if j < 0 || (tokens[j].kind != tokWord && tokens[j].kind != tokQuoted) {
+		return ""
+	}
+	return unquote(tokens[j])
+}
+
+// classifyArg returns the masked value of the argument at index when the classifier labels it.
+func (op *operation) classifyArg(columns map[int]string, index int, value any) (any, bool) {
+	label := op.classifier.Classify(op.ctx, columns[index], value)
+	if label == "" {
+		return value, false
+	}
+	return classifiedValue(label), true
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"maps"
+	"testing"
+)
+
+func TestArgColumns(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  map[int]string
+	}{
+		{"comparisons", "SELECT * FROM u WHERE email = ? AND age > ?", map[int]string{0: "email", 1: "age"}},
+		{"in list", "SELECT * FROM u WHERE email = ? AND id IN (?, ?)", map[int]string{0: "email", 1: "id", 2: "id"}},
+		{"qualified and quoted", `SELECT * FROM u WHERE "u"."email" <> ? LIMIT ?`, map[int]string{0: "email"}},
+		{"numbered placeholders", "UPDATE u SET name = $2 WHERE id = $1", map[int]string{0: "id", 1: "name"}},
+		{"insert rows", "INSERT INTO u (name, email) VALUES (?, ?), (?, ?)", map[int]string{0: "name", 1: "email", 2: "name", 3: "email"}},
+		{"insert numbered", "INSERT INTO u (name, email) VALUES ($1, $2)", map[int]string{0: "name", 1: "email"}},
+		{"insert without columns", "INSERT INTO u VALUES (?, ?)", map[int]string{}},
+		{"no placeholders", "SELECT * FROM u WHERE email = 'x'", map[int]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := argColumns(significant(scanSQL(tt.query))); !maps.Equal(got, tt.want) {
+				t.Errorf("argColumns(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultClassifier(t *testing.T) {
+	tests := []struct {
+		name   string
+		column string
+		value  any
+		want   string
+	}{
+		{"password column", "password", "hunter2", "secret"},
+		{"qualified secret column", "client_secret", "x", "secret"},
+		{"token column", "access_token", "x", "secret"},
+		{"api key column", "apikey", "x", "secret"},
+		{"hashed password column", "password_hash", "x", "secret"},
+		{"upper case column", "API_KEY", "x", "secret"},
+		{"token count column", "token_count", 3, ""},
+		{"secretary column", "secretary_id", 42, ""},
+		{"password timestamp column", "password_updated_at", "2024-01-01", ""},
+		{"tokens column", "tokens", 3, ""},
+		{"email column", "email", "x", "email"},
+		{"email value", "note", "write to a.b@example.com", "email"},
+		{"card number", "note", "4111 1111 1111 1111", "card_number"},
+		{"bytes card number", "note", []byte("4111111111111111"), "card_number"},
+		{"id failing luhn", "order_id", "4111111111111112", ""},
+		{"nanosecond timestamp", "created_at", "1704067200000000001", ""},
+		{"phone value", "note", "+1 555 123 4567", "phone"},
+		{"plain value", "name", "alice", ""},
+	}
+	c := DefaultClassifier()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Classify(context.Background(), tt.column, tt.value); got != tt.want {
+				t.Errorf("Classify(%q, %v) = %q, want %q", tt.column, tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"strings"
+)
+
+// ANSI escape sequences used to colorize pretty-printed queries.
+const (
+	ansiKeyword = "\x1b[1;34m"
+	ansiLiteral = "\x1b[32m"
+	ansiParam   = "\x1b[33m"
+	ansiComment = "\x1b[90m"
+	ansiReset   = "\x1b[0m"
+)
+
+// clauseKeywords start a new line in pretty-printed queries.
+var clauseKeywords = map[string]bool{
+	"WITH": true, "SELECT": true, "FROM": true, "WHERE": true, "GROUP": true, "ORDER": true,
+	"HAVING": true, "LIMIT": true, "OFFSET": true, "UNION": true, "INTERSECT": true, "EXCEPT": true,
+	"INSERT": true, "VALUES": true, "UPDATE": true, "SET": true, "DELETE": true, "RETURNING": true,
+	"JOIN": true, "LEFT": true, "RIGHT": true, "INNER": true, "FULL": true, "CROSS": true, "NATURAL": true,
+}
+
+// joinModifiers may precede JOIN on the same line.
+var joinModifiers = map[string]bool{
+	"LEFT": true, "RIGHT": true, "INNER": true, "OUTER": true, "FULL": true, "CROSS": true, "NATURAL": true,
+}
+
+// sqlKeywords are the keywords colorized in pretty-printed queries, besides clauseKeywords.
+var sqlKeywords = map[string]bool{
+	"AS": true, "ON": true, "AND": true, "OR": true, "NOT": true, "IN": true, "IS": true, "NULL": true,
+	"LIKE": true, "ILIKE": true, "BETWEEN": true, "BY": true, "DISTINCT": true, "ALL": true, "ANY": true,
+	"CASE": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true, "EXISTS": true, "INTO": true,
+	"ASC": true, "DESC": true, "OUTER": true, "CONFLICT": true, "DO": true, "NOTHING": true, "DEFAULT": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "TABLE": true, "INDEX": true, "PRIMARY": true, "KEY": true,
+	"FOREIGN": true, "REFERENCES": true, "UNIQUE": true, "CONSTRAINT": true, "TRUE": true, "FALSE": true,
+}
+
+// prettySQL reindents query with one clause per line, conditions joined by AND or OR on
+// their own lines and subqueries indented by their nesting depth. Keywords, literals,
+// placeholders and comments are colorized with ANSI escape sequences when color is set.
+func prettySQL(query string, color bool) string {
+	tokens := scanSQL(query)
+	var b strings.Builder
+	b.Grow(len(query) * 2)
+	depth, between := 0, false
+	pendingSpace, lineComment := false, false
+	prev := ""
+	newline := func(extra int) {
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+			b.WriteString(strings.Repeat("  ", depth+extra))
+		}
+		pendingSpace = false
+	}
+	for i, t := range tokens {
+		if t.kind == tokSpace {
+			pendingSpace = true
+			continue
+		}
+		upper := ""
+		if t.kind == tokWord {
+			upper = strings.ToUpper(t.text)
+		}
+		switch {
+		case upper == "JOIN" && joinModifiers[prev]:
+		case (upper == "LEFT" || upper == "RIGHT") && nextIs(tokens, i, "("):
+		case joinModifiers[upper] && joinModifiers[prev]:
+		case clauseKeywords[upper]:
+			newline(0)
+			lineComment = false
+		case (upper == "AND" && !between) || upper == "OR":
+			newline(1)
+			lineComment = false
+		}
+		if lineComment {
+			// A line comment runs to the end of the line, the next token must not join it.
+			newline(0)
+			lineComment = false
+		}
+		if upper == "AND" {
+			between = false
+		}
+		if upper == "BETWEEN" {
+			between = true
+		}
+		if pendingSpace && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		pendingSpace = false
+		switch {
+		case !color:
+			b.WriteString(t.text)
+		case t.kind == tokWord && (clauseKeywords[upper] || sqlKeywords[upper]):
+			b.WriteString(ansiKeyword + t.text + ansiReset)
+		case t.kind == tokString || t.kind == tokNumber:
+			b.WriteString(ansiLiteral + t.text + ansiReset)
+		case t.kind == tokParam:
+			b.WriteString(ansiParam + t.text + ansiReset)
+		case t.kind == tokComment:
+			b.WriteString(ansiComment + t.text + ansiReset)
+		default:
+			b.WriteString(t.text)
+		}
+		switch {
+		case t.text == "(":
+			depth++
+		case t.text == ")" && depth > 0:
+			depth--
+		case t.kind == tokComment && strings.HasPrefix(t.text, "--"):
+			lineComment = true
+		}
+		if t.kind != tokComment {
+			prev = upper
+		}
+	}
+	return b.String()
+}
+
+// nextIs reports whether the first significant token after tokens[i] is text.
+func nextIs(tokens []token, i int, text string) bool {
+	for _, t := range tokens[i+1:] {
+		if t.kind != tokSpace && t.kind != tokComment {
+			return t.text == text
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// readOnlyTx records whether a write succeeding in a read-only transaction was reported.
+type readOnlyTx struct {
+	warned atomic.Bool
+}
+
+// checkReadOnly warns, once per transaction, when a write succeeds in a transaction begun
+// read-only, meaning the driver, the database or a proxy silently ignored the option.
+func (op *operation) checkReadOnly(err error) {
+	if op.readOnly == nil || err != nil {
+		return
+	}
+	switch op.class {
+	case ClassInsert, ClassUpdate, ClassDelete, ClassDDL:
+	default:
+		return
+	}
+	if !op.readOnly.warned.CompareAndSwap(false, true) {
+		return
+	}
+	attrs := append(op.queryAttrs(op.sensitive), op.labelAttrs()...)
+	op.logAt(op.ctx, slog.LevelWarn, "write succeeded in read-only transaction", attrs...)
+}
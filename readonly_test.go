@@ -0,0 +1,63 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"testing"
+
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/sql"
+)
+
+func TestTxReadOnlyDowngradeDetection(t *testing.T) {
+	tests := []struct {
+		name     string
+		detect   bool
+		readOnly bool
+		queries  []string
+		warned   bool
+	}{
+		{"writes", true, true, []string{"SELECT a FROM t", "UPDATE t SET a = ?", "DELETE FROM t WHERE a = ?"}, true},
+		{"reads", true, true, []string{"SELECT a FROM t", "SELECT b FROM u"}, false},
+		{"read-write", true, false, []string{"UPDATE t SET a = ?"}, false},
+		{"disabled", false, true, []string{"UPDATE t SET a = ?"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log testLog
+			ss := []Setting{WithLogger(log.Logger())}
+			if tt.detect {
+				ss = append(ss, WithTxReadOnlyDowngradeDetection())
+			}
+			drv := New(newTimedDriver(dialect.SQLite, 0), ss...)
+			tx, err := drv.(*SlogDriver).BeginTx(context.Background(), &sql.TxOptions{ReadOnly: tt.readOnly})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, query := range tt.queries {
+				if err := tx.Exec(context.Background(), query, []any{1}, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tx.Rollback(); err != nil {
+				t.Fatal(err)
+			}
+			records := log.Records(t, "write succeeded in read-only transaction")
+			if !tt.warned {
+				if len(records) != 0 {
+					t.Errorf("got read-only warnings %v", records)
+				}
+				return
+			}
+			if len(records) != 1 {
+				t.Fatalf("got %d read-only warnings, want 1", len(records))
+			}
+			record := records[0]
+			if record["level"] != "WARN" || record["query"] != "UPDATE t SET a = ?" || record["op"] != ClassUpdate || record["id"] == nil {
+				t.Errorf("read-only warning = %v", record)
+			}
+		})
+	}
+}
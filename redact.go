@@ -0,0 +1,147 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type (
+	// ArgFilterFunc returns the value logged for the bind argument at index, e.g. a mask for passwords and tokens.
+	ArgFilterFunc func(ctx context.Context, index int, value any) any
+	// QueryFilterFunc returns the query text logged for query, e.g. with personal data masked.
+	QueryFilterFunc func(ctx context.Context, query string) string
+)
+
+// redactedValue replaces values that must not appear in logs.
+const redactedValue = "[REDACTED]"
+
+// hasSensitiveTable reports whether one of tables is a configured sensitive table.
+// A schema-qualified table matches both its qualified and its bare name.
+func (h *Handler) hasSensitiveTable(tables []string) bool {
+	if len(h.sensitiveTables) == 0 {
+		return false
+	}
+	for _, table := range tables {
+		table = strings.ToLower(table)
+		if h.sensitiveTables[table] {
+			return true
+		}
+		if i := strings.LastIndexByte(table, '.'); i >= 0 && h.sensitiveTables[table[i+1:]] {
+			return true
+		}
+	}
+	return false
+}
+
+// isSensitiveColumn reports whether column is one of the configured sensitive columns.
+func (h *Handler) isSensitiveColumn(column string) bool {
+	return column != "" && h.sensitiveCols[strings.ToLower(column)]
+}
+
+// redactArgs masks every bind argument while preserving their number.
+func redactArgs(args any) any {
+	switch args := args.(type) {
+	case nil:
+		return nil
+	case []any:
+		masked := make([]any, len(args))
+		for i := range masked {
+			masked[i] = redactedValue
+		}
+		return masked
+	}
+	return redactedValue
+}
+
+// filterArgs masks the bind arguments bound to sensitive columns or labeled by the
+// classifier, passes the others through the sanitizers and the argument filter, if any,
+// and hashes them or summarizes the large ones.
+func (op *operation) filterArgs(args any) any {
+	if op.argFilter == nil && op.classifier == nil && len(op.sensitiveCols) == 0 && op.maxArgSize <= 0 && op.argSalt == nil &&
+		op.sanitizer == nil {
+		return args
+	}
+	var columns map[int]string
+	if op.classifier != nil || op.sanitizer != nil || len(op.sensitiveCols) > 0 {
+		columns = argColumns(op.tokens)
+	}
+	filter := func(i int, v any) any {
+		if op.isSensitiveColumn(columns[i]) {
+			return redactedValue
+		}
+		if op.classifier != nil {
+			if masked, ok := op.classifyArg(columns, i, v); ok {
+				return masked
+			}
+		}
+		if op.sanitizer != nil {
+			v = op.sanitizer.Sanitize(op.ctx, columns[i], v)
+		}
+		if op.argFilter != nil {
+			v = op.argFilter(op.ctx, i, v)
+		}
+		switch {
+		case op.argSalt != nil:
+			v = hashArg(v, op.argSalt)
+		case op.maxArgSize > 0:
+			v = summarizeArg(v, op.maxArgSize)
+		}
+		return v
+	}
+	switch args := args.(type) {
+	case nil:
+		return nil
+	case []any:
+		filtered := make([]any, len(args))
+		for i, v := range args {
+			filtered[i] = filter(i, v)
+		}
+		return filtered
+	}
+	return filter(0, args)
+}
+
+// summarizeArg returns a summary of the byte slices and strings longer than size bytes,
+// such as bytes(len=10240, sha256=9f86d081…), or v itself otherwise.
+func summarizeArg(v any, size int) any {
+	rv := reflect.ValueOf(v)
+	var kind string
+	switch {
+	case !rv.IsValid():
+		return v
+	case rv.Kind() == reflect.String:
+		kind = "string"
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8:
+		kind = "bytes"
+	default:
+		return v
+	}
+	if rv.Len() <= size {
+		return v
+	}
+	var sum [sha256.Size]byte
+	if kind == "string" {
+		sum = sha256.Sum256([]byte(rv.String()))
+	} else {
+		sum = sha256.Sum256(rv.Bytes())
+	}
+	return fmt.Sprintf("%s(len=%d, sha256=%x…)", kind, rv.Len(), sum[:4])
+}
+
+// hashArg returns the salted hash of the SQL literal of v, so equal values hash equally
+// whatever their Go type, or nil for nil.
+func hashArg(v any, salt []byte) any {
+	if v == nil {
+		return nil
+	}
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(sqlLiteral(v, "")))
+	return fmt.Sprintf("hash:%x", mac.Sum(nil)[:8])
+}
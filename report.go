@@ -0,0 +1,114 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// reportTopQueries is the number of queries listed by a report.
+	reportTopQueries = 10
+	// reportQueryWidth is the maximum width of the queries printed in a report.
+	reportQueryWidth = 120
+)
+
+// reportRecorder is an Observer accumulating the statistics of the current report period.
+type reportRecorder struct {
+	out     sync.Mutex // serializes the reports written concurrently by a tick and Close.
+	mu      sync.Mutex
+	since   time.Time
+	ops     map[string]*opRecorder // by operation kind: exec, query, tx, commit or rollback.
+	queries map[string]*opRecorder // by statement fingerprint.
+}
+
+func newReportRecorder() *reportRecorder {
+	r := new(reportRecorder)
+	r.reset(time.Now())
+	return r
+}
+
+func (r *reportRecorder) reset(now time.Time) {
+	r.since = now
+	r.ops = make(map[string]*opRecorder)
+	r.queries = make(map[string]*opRecorder)
+}
+
+// Observe implements Observer.
+func (r *reportRecorder) Observe(_ context.Context, e Event) {
+	fp := e.Fingerprint
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	recorderOf(r.ops, counterName(e.Op)).observe(e.Duration, e.Err != nil)
+	if fp == "" {
+		return
+	}
+	recorderOf(r.queries, queryKey(r.queries, fp)).observe(e.Duration, e.Err != nil)
+}
+
+// write writes the report of the period ending at now to w and starts a new period.
+func (r *reportRecorder) write(w io.Writer, dialect string, now time.Time) error {
+	r.out.Lock()
+	defer r.out.Unlock()
+	r.mu.Lock()
+	since, ops, queries := r.since, r.ops, r.queries
+	r.reset(now)
+	r.mu.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "entslog report %s - %s (%s)\n\n", since.Format(time.RFC3339), now.Format(time.RFC3339), dialect)
+	fmt.Fprintln(tw, "OPERATION\tCOUNT\tERRORS\tERROR RATE\tP50\tP99\tMAX")
+	for _, name := range []string{"exec", "query", "tx", "commit", "rollback"} {
+		if rec, ok := ops[name]; ok {
+			s := rec.snapshot()
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f%%\t%s\t%s\t%s\n", name, s.Count, s.Errors, errorRate(s), s.P50, s.P99, s.Max)
+		}
+	}
+	type entry struct {
+		query string
+		stats OperationStats
+	}
+	top := make([]entry, 0, len(queries))
+	for query, rec := range queries {
+		top = append(top, entry{query: query, stats: rec.snapshot()})
+	}
+	slices.SortFunc(top, func(a, b entry) int {
+		return cmp.Or(cmp.Compare(b.stats.Total, a.stats.Total), cmp.Compare(a.query, b.query))
+	})
+	fmt.Fprintf(tw, "\nTOP QUERIES BY TOTAL TIME\nTOTAL\tCOUNT\tERRORS\tP50\tP99\tMAX\tQUERY\n")
+	for _, e := range top[:min(len(top), reportTopQueries)] {
+		s := e.stats
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n", s.Total, s.Count, s.Errors, s.P50, s.P99, s.Max, truncateQuery(e.query))
+	}
+	fmt.Fprintln(tw)
+	return tw.Flush()
+}
+
+func errorRate(s OperationStats) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return 100 * float64(s.Errors) / float64(s.Count)
+}
+
+func truncateQuery(query string) string {
+	if len(query) <= reportQueryWidth {
+		return query
+	}
+	return truncate(query, reportQueryWidth-len(truncationMarker)) + truncationMarker
+}
+
+// writeReport writes the report of the current period, logging write failures.
+func (h *Handler) writeReport(w io.Writer) {
+	if err := h.report.write(w, h.dialect, time.Now()); err != nil {
+		h.LogError(context.Background(), "report", err)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+)
+
+// requestIDKey is the context key under which EnsureRequestID stores the request id.
+type requestIDKey struct{}
+
+// requestID is the request id of a context, along with the number of ids derived from it.
+type requestID struct {
+	id  string
+	seq atomic.Uint64
+}
+
+// EnsureRequestID returns ctx and the request id it carries, or, when it carries none,
+// a copy of ctx carrying a new random request id. Every record logged under the returned
+// context, or under contexts derived from it, carries the id in a `request_id` attribute,
+// giving per-request SQL correlation to applications without tracing infrastructure.
+// The id is honored by the trace chain: it is the trace id of WithContextTrace, and the
+// default trace function, also the fallback of TraceOTel, derives the transaction ids
+// from it, e.g. `0123456789abcdef-1`. It is meant to be called once per request, e.g. by
+// an HTTP middleware.
+func EnsureRequestID(ctx context.Context) (context.Context, string) {
+	if id := RequestIDFromContext(ctx); id != "" {
+		return ctx, id
+	}
+	id := fmt.Sprintf("%016x", rand.Uint64())
+	return context.WithValue(ctx, requestIDKey{}, &requestID{id: id}), id
+}
+
+// RequestIDFromContext returns the request id stored by EnsureRequestID, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	if r, ok := ctx.Value(requestIDKey{}).(*requestID); ok {
+		return r.id
+	}
+	return ""
+}
+
+// derivedRequestID returns a new id made of the request id of ctx and a sequence
+// number, unique within the request, or false when ctx carries no request id.
+func derivedRequestID(ctx context.Context) (string, bool) {
+	r, ok := ctx.Value(requestIDKey{}).(*requestID)
+	if !ok {
+		return "", false
+	}
+	return r.id + "-" + strconv.FormatUint(r.seq.Add(1), 10), true
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEnsureRequestID(t *testing.T) {
+	ctx, id := EnsureRequestID(context.Background())
+	if id == "" || RequestIDFromContext(ctx) != id {
+		t.Fatalf("RequestIDFromContext = %q, want %q", RequestIDFromContext(ctx), id)
+	}
+	if again, same := EnsureRequestID(ctx); again != ctx || same != id {
+		t.Errorf("EnsureRequestID replaced the request id %q with %q", id, same)
+	}
+	if got := RequestIDFromContext(context.Background()); got != "" {
+		t.Errorf("RequestIDFromContext of a bare context = %q", got)
+	}
+}
+
+func TestRequestIDTraceChain(t *testing.T) {
+	ctx, id := EnsureRequestID(context.Background())
+	for _, root := range []bool{false, true} {
+		var log testLog
+		fake := &fakeDriver{clock: newTestClock()}
+		drv := newTestDriver(fake, &log, WithContextTrace())
+		ctx := ctx
+		if root {
+			ctx = ContextWithTrace(ctx)
+		}
+		if err := drv.Exec(ctx, "UPDATE t SET a = 1", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		tx, err := drv.Tx(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Exec(ctx, "UPDATE t SET a = 2", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		statements := log.Records(t, "Exec")
+		if len(statements) != 2 {
+			t.Fatalf("got %d statement records, want 2", len(statements))
+		}
+		for _, record := range statements {
+			if record["trace_id"] != id || record["request_id"] != id {
+				t.Errorf("root %t: record %v, want trace_id and request_id %q", root, record, id)
+			}
+		}
+		records := log.Records(t, "Tx started")
+		if len(records) != 1 {
+			t.Fatalf("got %d Tx records, want 1", len(records))
+		}
+		if txID, _ := records[0]["id"].(string); !strings.HasPrefix(txID, id+"-") {
+			t.Errorf("transaction id %q is not derived from the request id %q", txID, id)
+		}
+	}
+}
+
+func TestTraceFunctionsDeriveRequestID(t *testing.T) {
+	ctx, id := EnsureRequestID(context.Background())
+	seen := make(map[string]bool)
+	for _, trace := range []func(context.Context) string{traceUUID, TraceOTel, traceUUID} {
+		got := trace(ctx)
+		if !strings.HasPrefix(got, id+"-") || seen[got] {
+			t.Errorf("trace id %q, want a new id derived from %q", got, id)
+		}
+		seen[got] = true
+	}
+	if got := traceUUID(context.Background()); strings.HasPrefix(got, id) || len(got) != 36 {
+		t.Errorf("trace id without a request id = %q, want a UUID", got)
+	}
+}
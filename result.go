@@ -0,0 +1,34 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"database/sql"
+	"log/slog"
+)
+
+// recordResult keeps the rows affected and the last insert id of the result v of a
+// successful Exec, a sql.Result or a *sql.Result, for the outcome record.
+func (op *operation) recordResult(v any, err error) {
+	if !op.execResult || err != nil {
+		return
+	}
+	var res sql.Result
+	switch v := v.(type) {
+	case sql.Result:
+		res = v
+	case *sql.Result:
+		res = *v
+	}
+	if res == nil {
+		return
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		op.results = append(op.results, slog.Int64("rows_affected", n))
+	}
+	// Dialects without insert ids, such as PostgreSQL, return an error.
+	if id, err := res.LastInsertId(); err == nil {
+		op.results = append(op.results, slog.Int64("last_insert_id", id))
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"database/sql"
+	"log/slog"
+	"strconv"
+)
+
+// logResultSet logs the column names and types of the result set v of a successful
+// query, once per query fingerprint. v is a *entsql.Rows or a *database/sql.Rows.
+func (op *operation) logResultSet(v any, err error) {
+	if op.resultSchemas == nil || err != nil {
+		return
+	}
+	rows, ok := v.(interface {
+		ColumnTypes() ([]*sql.ColumnType, error)
+	})
+	if !ok {
+		return
+	}
+	fp := op.fingerprint()
+	if _, loaded := op.resultSchemas.LoadOrStore(fp, struct{}{}); loaded {
+		return
+	}
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		op.resultSchemas.Delete(fp)
+		return
+	}
+	columns := make([]any, 0, len(types))
+	for i, t := range types {
+		attrs := []any{slog.String("name", t.Name()), slog.String("type", t.DatabaseTypeName())}
+		if st := t.ScanType(); st != nil {
+			attrs = append(attrs, slog.String("scan_type", st.String()))
+		}
+		if nullable, ok := t.Nullable(); ok {
+			attrs = append(attrs, slog.Bool("nullable", nullable))
+		}
+		columns = append(columns, slog.Group(strconv.Itoa(i), attrs...))
+	}
+	op.Log(op.ctx, op.name+" result set", append(op.subjectAttrs(), slog.Group("columns", columns...))...)
+}
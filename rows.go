@@ -0,0 +1,95 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	entsql "entgo.io/ent/dialect/sql"
+)
+
+// loggedRows wraps the rows of a query so that, with WithScanErrorDetails, scan errors
+// are logged together with the column they occurred on, which the errors surfaced by ent
+// rarely make obvious, and, with WithRowCounts, the size of the result set is logged when
+// it is closed.
+type loggedRows struct {
+	entsql.ColumnScanner
+	h     *Handler
+	ctx   context.Context
+	name  string
+	attrs []slog.Attr // labels and fingerprint of the query.
+	start time.Time   // time the result set was returned.
+	rows  int         // rows iterated so far.
+	done  bool        // whether Close has been called.
+	leak  *rowsLeak   // reports the result set when it is not closed, nil unless WithRowsLeakDetection is set.
+}
+
+// wrapRows installs loggedRows on the result set v of a successful query.
+func (op *operation) wrapRows(v any, err error) {
+	if (!op.scanErrors && !op.rowCounts && !op.leakCheck) || err != nil {
+		return
+	}
+	rows, ok := v.(*entsql.Rows)
+	if !ok || rows.ColumnScanner == nil {
+		return
+	}
+	r := &loggedRows{
+		ColumnScanner: rows.ColumnScanner,
+		h:             op.Handler,
+		ctx:           op.ctx,
+		name:          op.name,
+		attrs:         op.subjectAttrs(),
+		start:         op.now(),
+	}
+	if op.leakCheck {
+		op.watchLeak(r)
+	}
+	rows.ColumnScanner = r
+}
+
+func (r *loggedRows) Next() bool {
+	if !r.ColumnScanner.Next() {
+		return false
+	}
+	r.rows++
+	return true
+}
+
+func (r *loggedRows) Scan(dest ...any) error {
+	err := r.ColumnScanner.Scan(dest...)
+	if err != nil && r.h.scanErrors {
+		r.h.LogError(r.ctx, r.name+" scan", err, r.scanAttrs(err, dest)...)
+	}
+	return err
+}
+
+func (r *loggedRows) Close() error {
+	err := r.ColumnScanner.Close()
+	if r.done {
+		return err
+	}
+	r.done = true
+	if r.leak != nil {
+		r.leak.stop()
+		runtime.SetFinalizer(r, nil)
+	}
+	if !r.h.rowCounts {
+		return err
+	}
+	attrs := append(r.attrs[:len(r.attrs):len(r.attrs)],
+		slog.Int("rows", r.rows),
+		slog.Duration("duration", r.h.now().Sub(r.start)),
+	)
+	if iterErr := r.ColumnScanner.Err(); iterErr != nil {
+		r.h.LogError(r.ctx, r.name+" rows", iterErr, attrs...)
+	}
+	if err != nil {
+		return r.h.LogError(r.ctx, r.name+" rows close", err, attrs...)
+	}
+	r.h.Log(r.ctx, r.name+" rows closed", attrs...)
+	return nil
+}
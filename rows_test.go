@@ -0,0 +1,94 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	stdsql "database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"entgo.io/ent/dialect/sql"
+)
+
+// rowsDriver is a fake driver whose queries return scanner as the result set.
+type rowsDriver struct {
+	*fakeDriver
+	scanner sql.ColumnScanner
+}
+
+func (d *rowsDriver) Query(_ context.Context, _ string, _, v any) error {
+	v.(*sql.Rows).ColumnScanner = d.scanner
+	return nil
+}
+
+// failingScanner is a result set of one row whose Scan fails.
+type failingScanner struct {
+	sql.ColumnScanner
+	next bool
+}
+
+func (s *failingScanner) Next() bool                 { s.next = !s.next; return s.next }
+func (s *failingScanner) Columns() ([]string, error) { return []string{"name"}, nil }
+func (s *failingScanner) Close() error               { return nil }
+func (s *failingScanner) Err() error                 { return nil }
+
+func (s *failingScanner) Scan(...any) error {
+	return errors.New(`sql: Scan error on column index 0, name "name": converting NULL to string is unsupported`)
+}
+
+func (s *failingScanner) ColumnTypes() ([]*stdsql.ColumnType, error) {
+	return nil, errors.New("no column types")
+}
+
+func TestWrapRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		ss      []Setting
+		wrapped bool
+		logged  bool
+	}{
+		{"default", nil, false, false},
+		{"scan errors", []Setting{WithScanErrorDetails()}, true, true},
+		{"row counts", []Setting{WithRowCounts()}, true, false},
+		{"leak detection", []Setting{WithRowsLeakDetection(time.Minute)}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log testLog
+			scanner := &failingScanner{}
+			drv := New(&rowsDriver{fakeDriver: &fakeDriver{clock: newTestClock()}, scanner: scanner},
+				append([]Setting{WithLogger(log.Logger())}, tt.ss...)...)
+			var rows sql.Rows
+			if err := drv.Query(context.Background(), "SELECT name FROM u", []any{}, &rows); err != nil {
+				t.Fatal(err)
+			}
+			if _, wrapped := rows.ColumnScanner.(*loggedRows); wrapped != tt.wrapped {
+				t.Fatalf("result set wrapped = %t, want %t", wrapped, tt.wrapped)
+			}
+			rows.Next()
+			var name string
+			if err := rows.Scan(&name); err == nil {
+				t.Fatal("Scan succeeded")
+			}
+			rows.Close()
+			records := log.Records(t, "Query scan")
+			if !tt.logged {
+				if len(records) != 0 {
+					t.Errorf("got scan records %v, want none", records)
+				}
+				return
+			}
+			if len(records) != 1 {
+				t.Fatalf("got %d scan records, want 1", len(records))
+			}
+			record := records[0]
+			if record["column"] != "name" || record["column_index"] != 0.0 || record["go_type"] != "string" ||
+				record["fingerprint"] != fingerprint("SELECT name FROM u") {
+				t.Errorf("scan record = %v", record)
+			}
+		})
+	}
+}
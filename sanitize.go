@@ -0,0 +1,133 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+type (
+	// Sanitizer returns the value logged for a bind argument, with the personal data it
+	// contains masked. Sanitizers are chained, each receiving the result of the previous
+	// one, and must return value unchanged when it contains nothing to mask.
+	Sanitizer interface {
+		// Sanitize returns the sanitized value. column is the column the argument is bound
+		// to when it can be derived from the statement, or empty otherwise.
+		Sanitize(ctx context.Context, column string, value any) any
+	}
+	// SanitizerFunc is a function implementing Sanitizer.
+	SanitizerFunc func(ctx context.Context, column string, value any) any
+	// Detector is a Sanitizer masking the matches of Pattern in strings, byte slices and
+	// fmt.Stringer values with the label, such as [REDACTED:email]. The masked values are
+	// logged as strings.
+	Detector struct {
+		Label   string                  // Label names the detected data in the mask, e.g. email.
+		Pattern *regexp.Regexp          // Pattern matches the candidate data.
+		Valid   func(match string) bool // Valid confirms the candidate matches, optional, e.g. a checksum.
+	}
+	// sanitizerChain applies its sanitizers in order.
+	sanitizerChain []Sanitizer
+)
+
+// Sanitize implements Sanitizer.
+func (f SanitizerFunc) Sanitize(ctx context.Context, column string, value any) any {
+	return f(ctx, column, value)
+}
+
+// Sanitize implements Sanitizer.
+func (d *Detector) Sanitize(_ context.Context, _ string, value any) any {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	case fmt.Stringer:
+		text = v.String()
+	default:
+		return value
+	}
+	masked := d.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+		if d.Valid != nil && !d.Valid(match) {
+			return match
+		}
+		return classifiedValue(d.Label)
+	})
+	if masked == text {
+		return value
+	}
+	return masked
+}
+
+func (c sanitizerChain) Sanitize(ctx context.Context, column string, value any) any {
+	for _, s := range c {
+		value = s.Sanitize(ctx, column, value)
+	}
+	return value
+}
+
+// ChainSanitizers returns a Sanitizer applying ss in order, each to the result of the previous one.
+func ChainSanitizers(ss ...Sanitizer) Sanitizer {
+	return sanitizerChain(ss)
+}
+
+// The patterns shared by the detectors and DefaultClassifier.
+var (
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	cardNumberPattern = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+)
+
+// EmailDetector returns a Detector masking email addresses.
+func EmailDetector() *Detector {
+	return &Detector{
+		Label:   "email",
+		Pattern: emailPattern,
+	}
+}
+
+// PhoneDetector returns a Detector masking international phone numbers, such as +1 555 123 4567.
+func PhoneDetector() *Detector {
+	return &Detector{
+		Label:   "phone",
+		Pattern: regexp.MustCompile(`\+\d{1,3}[ .-]?\(?\d{2,4}\)?[ .-]?\d{3,4}[ .-]?\d{3,4}\b`),
+	}
+}
+
+// CardNumberDetector returns a Detector masking payment card numbers of 13 to 19 digits,
+// optionally grouped by spaces or dashes. Only numbers passing the Luhn check are masked,
+// so order numbers and other identifiers of the same length are logged.
+func CardNumberDetector() *Detector {
+	return &Detector{
+		Label:   "card_number",
+		Pattern: cardNumberPattern,
+		Valid:   luhn,
+	}
+}
+
+// DefaultSanitizer returns the chain of the email, phone and card number detectors.
+func DefaultSanitizer() Sanitizer {
+	return ChainSanitizers(EmailDetector(), CardNumberDetector(), PhoneDetector())
+}
+
+// luhn reports whether the digits of number, ignoring separators, pass the Luhn check.
+func luhn(number string) bool {
+	sum, n := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if !isDigit(c) {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"entgo.io/ent/dialect"
+)
+
+// Savepointer is implemented by transactions supporting nested transactions through savepoints.
+// SlogTx implements it, forwarding to the underlying transaction when it implements it too.
+type Savepointer interface {
+	// Savepoint starts a nested transaction by setting the savepoint name.
+	Savepoint(ctx context.Context, name string) error
+	// ReleaseSavepoint commits the nested transaction of the savepoint name.
+	ReleaseSavepoint(ctx context.Context, name string) error
+	// RollbackToSavepoint rolls back the nested transaction of the savepoint name, which is kept.
+	RollbackToSavepoint(ctx context.Context, name string) error
+}
+
+// savepoint is a nested transaction of a SlogTx.
+type savepoint struct {
+	name string
+	id   string // logging id of the nested transaction.
+}
+
+// Savepoint logs this step and sets the savepoint name through the underlying transaction,
+// or with a SAVEPOINT statement when it does not implement Savepointer. The records of the
+// nested transaction carry its own id in a `savepoint_id` attribute, next to the `id` of
+// the parent transaction.
+func (d *SlogTx) Savepoint(ctx context.Context, name string) error {
+	id := d.WithTrace(ctx)
+	err := d.savepointOp(ctx, "Savepoint", name, id, func(sp Savepointer) error {
+		return sp.Savepoint(ctx, name)
+	})
+	if err == nil {
+		d.spMu.Lock()
+		d.savepoints = append(d.savepoints, savepoint{name: name, id: id})
+		d.spMu.Unlock()
+	}
+	return err
+}
+
+// ReleaseSavepoint logs this step and releases the savepoint name through the underlying
+// transaction, or with a RELEASE SAVEPOINT statement when it does not implement Savepointer.
+func (d *SlogTx) ReleaseSavepoint(ctx context.Context, name string) error {
+	// Releasing a savepoint also releases the savepoints set after it.
+	id, i := d.lookupSavepoint(name)
+	err := d.savepointOp(ctx, "ReleaseSavepoint", name, id, func(sp Savepointer) error {
+		return sp.ReleaseSavepoint(ctx, name)
+	})
+	if err == nil && i >= 0 {
+		d.truncateSavepoints(i)
+	}
+	return err
+}
+
+// RollbackToSavepoint logs this step and rolls back to the savepoint name through the
+// underlying transaction, or with a ROLLBACK TO SAVEPOINT statement when it does not
+// implement Savepointer.
+func (d *SlogTx) RollbackToSavepoint(ctx context.Context, name string) error {
+	// Rolling back to a savepoint keeps it but destroys the savepoints set after it.
+	id, i := d.lookupSavepoint(name)
+	err := d.savepointOp(ctx, "RollbackToSavepoint", name, id, func(sp Savepointer) error {
+		return sp.RollbackToSavepoint(ctx, name)
+	})
+	if err == nil && i >= 0 {
+		d.truncateSavepoints(i + 1)
+	}
+	return err
+}
+
+// savepointOp logs the savepoint operation and runs it with forward when the underlying
+// transaction implements Savepointer, or with its statement otherwise.
+func (d *SlogTx) savepointOp(ctx context.Context, name, savepoint, id string, forward func(Savepointer) error) error {
+	op := d.begin(ctx, name, "")
+	attrs := []slog.Attr{slog.String("savepoint", savepoint), slog.String("savepoint_id", id)}
+	sp, ok := d.tx.(Savepointer)
+	var query string
+	if !ok {
+		query = savepointStatement(d.dialect, name, savepoint)
+		attrs = append(attrs, slog.String("query", query))
+	}
+	op.logLifecycle(name, attrs...)
+	var err error
+	if ok {
+		err = forward(sp)
+	} else {
+		err = d.tx.Exec(op.ctx, query, []any{}, nil)
+	}
+	return op.end(err)
+}
+
+// lookupSavepoint returns the id and index of the latest savepoint name, or an empty id
+// and -1 when it was not set through the transaction.
+func (d *SlogTx) lookupSavepoint(name string) (string, int) {
+	d.spMu.Lock()
+	defer d.spMu.Unlock()
+	for i := len(d.savepoints) - 1; i >= 0; i-- {
+		if d.savepoints[i].name == name {
+			return d.savepoints[i].id, i
+		}
+	}
+	return "", -1
+}
+
+// truncateSavepoints forgets the savepoints from index i on.
+func (d *SlogTx) truncateSavepoints(i int) {
+	d.spMu.Lock()
+	defer d.spMu.Unlock()
+	d.savepoints = slices.Delete(d.savepoints, i, len(d.savepoints))
+}
+
+// savepointStatement returns the statement of the savepoint operation, with the savepoint
+// name quoted as an identifier of the dialect.
+func savepointStatement(dialectName, op, name string) string {
+	quote := `"`
+	if dialectName == dialect.MySQL {
+		quote = "`"
+	}
+	ident := quote + strings.ReplaceAll(name, quote, quote+quote) + quote
+	switch op {
+	case "ReleaseSavepoint":
+		return "RELEASE SAVEPOINT " + ident
+	case "RollbackToSavepoint":
+		return "ROLLBACK TO SAVEPOINT " + ident
+	}
+	return "SAVEPOINT " + ident
+}
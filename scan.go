@@ -0,0 +1,49 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// scanIndexPattern extracts the column index from the scan errors of database/sql.
+	scanIndexPattern = regexp.MustCompile(`Scan error on column index (\d+)`)
+	// scanValuePattern extracts the Go type of the value returned by the database driver.
+	scanValuePattern = regexp.MustCompile(`driver\.Value type (\S+)`)
+)
+
+// scanAttrs returns what can be derived about the failed scan: the column name, the Go type
+// of the destination and the database type of the column, or the column list when the
+// number of destinations does not match.
+func (r *loggedRows) scanAttrs(err error, dest []any) []slog.Attr {
+	attrs := r.attrs[:len(r.attrs):len(r.attrs)]
+	columns, _ := r.Columns()
+	m := scanIndexPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return append(attrs, slog.Any("columns", columns), slog.Int("destinations", len(dest)))
+	}
+	i, _ := strconv.Atoi(m[1])
+	attrs = append(attrs, slog.Int("column_index", i))
+	if i < len(columns) {
+		attrs = append(attrs, slog.String("column", columns[i]))
+	}
+	if i < len(dest) && dest[i] != nil {
+		t := reflect.TypeOf(dest[i])
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		attrs = append(attrs, slog.String("go_type", t.String()))
+	}
+	if types, err := r.ColumnTypes(); err == nil && i < len(types) && types[i].DatabaseTypeName() != "" {
+		attrs = append(attrs, slog.String("db_type", types[i].DatabaseTypeName()))
+	}
+	if m := scanValuePattern.FindStringSubmatch(err.Error()); m != nil {
+		attrs = append(attrs, slog.String("value_type", m[1]))
+	}
+	return attrs
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// coalesceWindow is the slack within which due tasks are run by the same wake-up.
+const coalesceWindow = 10 * time.Millisecond
+
+// Scheduler runs periodic tasks, such as the background reporters of the drivers,
+// from a single goroutine with one coalesced timer instead of one goroutine and
+// ticker per task. A manual scheduler runs no goroutine and only runs tasks when
+// Advance is called, which makes periodic features deterministic in tests.
+type Scheduler struct {
+	mu      sync.Mutex
+	tasks   taskHeap
+	now     time.Time     // current time of a manual scheduler.
+	manual  bool          // whether tasks only run on Advance.
+	running bool          // whether the background goroutine has been started.
+	stopped bool          // whether Stop has been called.
+	wake    chan struct{} // signals the goroutine that the task set changed.
+	stop    chan struct{} // closed by Stop.
+}
+
+// sharedScheduler is the process-wide scheduler used by WithTickerlessTimers.
+var sharedScheduler = NewScheduler()
+
+// NewScheduler returns a scheduler whose goroutine is started with the first task.
+func NewScheduler() *Scheduler {
+	return &Scheduler{wake: make(chan struct{}, 1), stop: make(chan struct{})}
+}
+
+// NewManualScheduler returns a scheduler driven by Advance, starting at now.
+func NewManualScheduler(now time.Time) *Scheduler {
+	s := NewScheduler()
+	s.manual, s.now = true, now
+	return s
+}
+
+// Every runs fn every interval until the returned cancel function is called. Tasks due
+// within coalesceWindow of each other run by the same wake-up, so shorter intervals run
+// at most once per wake-up. Like time.NewTicker, it panics when interval is not positive.
+func (s *Scheduler) Every(interval time.Duration, fn func()) (cancel func()) {
+	if interval <= 0 {
+		panic("entslog: non-positive interval for Scheduler.Every")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := &task{interval: interval, next: s.clock().Add(interval), fn: fn}
+	heap.Push(&s.tasks, t)
+	if !s.manual && !s.running && !s.stopped {
+		s.running = true
+		go s.run()
+	}
+	s.notify()
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if t.index >= 0 {
+			heap.Remove(&s.tasks, t.index)
+			s.notify()
+		}
+	}
+}
+
+// Advance moves the clock of a manual scheduler forward by d and runs the tasks
+// that became due, in the calling goroutine. It does nothing on other schedulers.
+func (s *Scheduler) Advance(d time.Duration) {
+	s.mu.Lock()
+	if !s.manual {
+		s.mu.Unlock()
+		return
+	}
+	s.now = s.now.Add(d)
+	due := s.due(s.now)
+	s.mu.Unlock()
+	for _, fn := range due {
+		fn()
+	}
+}
+
+// Stop stops the background goroutine; tasks no longer run afterwards.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stop)
+	}
+}
+
+func (s *Scheduler) clock() time.Time {
+	if s.manual {
+		return s.now
+	}
+	return time.Now()
+}
+
+// notify wakes the goroutine up without blocking, the caller must hold mu.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// due reschedules and returns the tasks due at now, coalescing those due shortly after.
+func (s *Scheduler) due(now time.Time) []func() {
+	var fns []func()
+	for len(s.tasks) > 0 && !s.tasks[0].next.After(now.Add(coalesceWindow)) {
+		t := s.tasks[0]
+		fns = append(fns, t.fn)
+		// Tasks collected by this pass must leave the window, even those due shortly after now.
+		for !t.next.After(now.Add(coalesceWindow)) {
+			t.next = t.next.Add(t.interval)
+		}
+		heap.Fix(&s.tasks, 0)
+	}
+	return fns
+}
+
+func (s *Scheduler) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		wait := time.Hour
+		if len(s.tasks) > 0 {
+			wait = time.Until(s.tasks[0].next)
+		}
+		s.mu.Unlock()
+		timer.Reset(max(wait, 0))
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			continue
+		case <-s.stop:
+			return
+		}
+		s.mu.Lock()
+		due := s.due(time.Now())
+		s.mu.Unlock()
+		for _, fn := range due {
+			fn()
+		}
+	}
+}
+
+// task is a periodic function registered with a Scheduler.
+type task struct {
+	interval time.Duration
+	next     time.Time
+	fn       func()
+	index    int // position in the heap, -1 once removed.
+}
+
+// taskHeap orders tasks by their next run time.
+type taskHeap []*task
+
+func (h taskHeap) Len() int           { return len(h) }
+func (h taskHeap) Less(i, j int) bool { return h[i].next.Before(h[j].next) }
+func (h taskHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *taskHeap) Push(x any) {
+	t := x.(*task)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *taskHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*h = old[:len(old)-1]
+	return t
+}
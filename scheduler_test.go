@@ -0,0 +1,94 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManualScheduler(t *testing.T) {
+	s := NewManualScheduler(time.Unix(0, 0))
+	var fast, slow int
+	s.Every(time.Second, func() { fast++ })
+	s.Every(3*time.Second, func() { slow++ })
+
+	s.Advance(999 * time.Millisecond)
+	if fast != 1 || slow != 0 {
+		t.Errorf("after 999ms: fast = %d, slow = %d, want 1, 0 (coalesced)", fast, slow)
+	}
+	s.Advance(time.Second)
+	if fast != 2 || slow != 0 {
+		t.Errorf("after 2s: fast = %d, slow = %d, want 2, 0", fast, slow)
+	}
+	s.Advance(time.Second)
+	if fast != 3 || slow != 1 {
+		t.Errorf("after 3s: fast = %d, slow = %d, want 3, 1", fast, slow)
+	}
+	// A long advance runs every due task once.
+	s.Advance(10 * time.Second)
+	if fast != 4 || slow != 2 {
+		t.Errorf("after 13s: fast = %d, slow = %d, want 4, 2", fast, slow)
+	}
+	s.Advance(time.Second)
+	if fast != 5 || slow != 2 {
+		t.Errorf("after 14s: fast = %d, slow = %d, want 5, 2", fast, slow)
+	}
+}
+
+func TestManualSchedulerCancel(t *testing.T) {
+	s := NewManualScheduler(time.Unix(0, 0))
+	var runs int
+	cancel := s.Every(time.Second, func() { runs++ })
+	s.Advance(time.Second)
+	cancel()
+	cancel()
+	s.Advance(time.Second)
+	if runs != 1 {
+		t.Errorf("runs = %d, want 1", runs)
+	}
+}
+
+func TestManualSchedulerShortInterval(t *testing.T) {
+	s := NewManualScheduler(time.Unix(0, 0))
+	var runs int
+	s.Every(time.Millisecond, func() { runs++ })
+	for i := 1; i <= 5; i++ {
+		s.Advance(time.Millisecond)
+		if runs != i {
+			t.Fatalf("after %d advances: runs = %d, want %d", i, runs, i)
+		}
+	}
+}
+
+func TestSchedulerNonPositiveInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Every(%v) did not panic", interval)
+				}
+			}()
+			NewManualScheduler(time.Unix(0, 0)).Every(interval, func() {})
+		}()
+	}
+}
+
+func TestSchedulerStop(t *testing.T) {
+	s := NewScheduler()
+	runs := make(chan struct{}, 1)
+	s.Every(time.Millisecond, func() {
+		select {
+		case runs <- struct{}{}:
+		default:
+		}
+	})
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task did not run")
+	}
+	s.Stop()
+	s.Stop()
+}
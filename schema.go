@@ -0,0 +1,122 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// SchemaProfile selects the attribute naming convention of the emitted records.
+type SchemaProfile int
+
+const (
+	// SchemaDefault keeps the attribute names of this package, e.g. `query`, `args`, `id`.
+	SchemaDefault SchemaProfile = iota
+	// SchemaECS maps attributes to the Elastic Common Schema, e.g. `db.statement`,
+	// `event.duration` (in nanoseconds) and `error.message`.
+	SchemaECS
+	// SchemaGELF maps attributes to GELF additional fields: every key is prefixed with
+	// an underscore, `id` becomes `_tx_id` and durations are sent as `<key>_ms` numbers.
+	SchemaGELF
+	// SchemaOTel maps attributes to the OpenTelemetry database semantic conventions, e.g.
+	// `db.statement`, `db.operation` and `db.sql.table`, and adds `db.system` to every record.
+	SchemaOTel
+)
+
+// ecsNames maps attribute names to their Elastic Common Schema equivalent.
+var ecsNames = map[string]string{
+	"query":    "db.statement",
+	"args":     "db.parameters",
+	"duration": "event.duration",
+	"error":    "error.message",
+	"id":       "transaction.id",
+	"database": "log.logger",
+}
+
+// otelNames maps attribute names to their OpenTelemetry semantic-convention equivalent.
+var otelNames = map[string]string{
+	"query":  "db.statement",
+	"op":     "db.operation",
+	"tables": "db.sql.table",
+	"error":  "exception.message",
+}
+
+// apply renames the top-level attributes according to the profile.
+func (p SchemaProfile) apply(attrs []slog.Attr) []slog.Attr {
+	if p == SchemaDefault || len(attrs) == 0 {
+		return attrs
+	}
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		switch p {
+		case SchemaECS:
+			out[i] = ecsAttr(a)
+		case SchemaGELF:
+			out[i] = gelfAttr(a)
+		case SchemaOTel:
+			out[i] = otelAttr(a)
+		default:
+			out[i] = a
+		}
+	}
+	return out
+}
+
+func ecsAttr(a slog.Attr) slog.Attr {
+	if name, ok := ecsNames[a.Key]; ok {
+		a.Key = name
+	}
+	switch {
+	case a.Value.Kind() == slog.KindDuration:
+		a.Value = slog.Int64Value(a.Value.Duration().Nanoseconds())
+	case a.Key == "error.message":
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(err.Error())
+		}
+	}
+	return a
+}
+
+func otelAttr(a slog.Attr) slog.Attr {
+	name, ok := otelNames[a.Key]
+	if !ok {
+		return a
+	}
+	a.Key = name
+	switch v := a.Value.Any().(type) {
+	case []string:
+		// db.sql.table is the primary table of the operation.
+		if len(v) > 0 {
+			a.Value = slog.StringValue(v[0])
+		}
+	case error:
+		a.Value = slog.StringValue(v.Error())
+	}
+	return a
+}
+
+func gelfAttr(a slog.Attr) slog.Attr {
+	key := a.Key
+	if key == "id" {
+		key = "tx_id"
+	}
+	if a.Value.Kind() == slog.KindDuration {
+		return slog.Float64(gelfKey(key)+"_ms", float64(a.Value.Duration())/float64(time.Millisecond))
+	}
+	a.Key = gelfKey(key)
+	return a
+}
+
+// gelfKey turns key into a valid GELF additional field name.
+func gelfKey(key string) string {
+	key = strings.Map(func(r rune) rune {
+		if r == '_' || r == '.' || r == '-' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return '_'
+	}, key)
+	return "_" + key
+}
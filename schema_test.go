@@ -0,0 +1,80 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchemaProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile SchemaProfile
+		slow    map[string]any // attributes of the slow record.
+		failed  map[string]any // attributes of the error record.
+		absent  []string
+	}{
+		{"default", SchemaDefault,
+			map[string]any{"query": "UPDATE t SET a = ?", "duration": float64(time.Second), "op": ClassUpdate, "dialect": "sqlite3"},
+			map[string]any{"error": "deadlock"},
+			nil},
+		{"ecs", SchemaECS,
+			map[string]any{"db.statement": "UPDATE t SET a = ?", "event.duration": float64(time.Second), "log.logger": "driver"},
+			map[string]any{"error.message": "deadlock"},
+			[]string{"query", "duration", "error"}},
+		{"gelf", SchemaGELF,
+			map[string]any{"_query": "UPDATE t SET a = ?", "_duration_ms": float64(1000), "_op": ClassUpdate, "_slow": true},
+			map[string]any{"_error": "deadlock"},
+			[]string{"query", "duration", "op", "error"}},
+		{"otel", SchemaOTel,
+			map[string]any{"db.statement": "UPDATE t SET a = ?", "db.operation": ClassUpdate, "db.sql.table": "t", "db.system": "sqlite"},
+			map[string]any{"exception.message": "deadlock"},
+			[]string{"query", "op", "tables", "dialect", "error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log testLog
+			fake := &fakeDriver{clock: newTestClock(), took: time.Second}
+			drv := newTestDriver(fake, &log, WithSchemaProfile(tt.profile), WithSlowThreshold(100*time.Millisecond))
+			if err := drv.Exec(context.Background(), "UPDATE t SET a = ?", []any{1}, nil); err != nil {
+				t.Fatal(err)
+			}
+			fake.err = errors.New("deadlock")
+			_ = drv.Exec(context.Background(), "UPDATE t SET a = ?", []any{1}, nil)
+			slow := log.Records(t, "Exec done")
+			if len(slow) != 1 {
+				t.Fatalf("got %d slow records, want 1", len(slow))
+			}
+			var failed map[string]any
+			for _, record := range log.Records(t, "Exec") {
+				for key := range tt.failed {
+					if record[key] != nil {
+						failed = record
+					}
+				}
+			}
+			if failed == nil {
+				t.Fatalf("no error record with %v", tt.failed)
+			}
+			for _, c := range []struct {
+				record map[string]any
+				want   map[string]any
+			}{{slow[0], tt.slow}, {failed, tt.failed}} {
+				for key, want := range c.want {
+					if got := c.record[key]; got != want {
+						t.Errorf("%s = %v, want %v in %v", key, got, want, c.record)
+					}
+				}
+				for _, key := range tt.absent {
+					if _, ok := c.record[key]; ok {
+						t.Errorf("%s present in %v", key, c.record)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+
+	"entgo.io/ent/dialect"
+)
+
+// SessionTagFunc returns the request id stored in a session variable by WithSessionTagging.
+type SessionTagFunc func(ctx context.Context) string
+
+// sessionStatement returns the statement storing a value in a session variable visible to
+// the server-side activity views of the dialect, or an empty string when there is none.
+func sessionStatement(name string) string {
+	switch name {
+	case dialect.Postgres:
+		// Scoped to the transaction, application_name is shown by pg_stat_activity.
+		return "SELECT set_config('application_name', $1, true)"
+	case dialect.MySQL:
+		// Shown by performance_schema.user_variables_by_thread.
+		return "SET @request_id = ?"
+	}
+	return ""
+}
+
+// tagSession stores the request id of the transaction in a session variable.
+func (t *SlogTx) tagSession(ctx context.Context) error {
+	query := sessionStatement(t.dialect)
+	if query == "" {
+		return nil
+	}
+	value := t.id
+	if t.sessionTag != nil {
+		value = t.sessionTag(ctx)
+	}
+	if err := t.tx.Exec(ctx, query, []any{value}, nil); err != nil {
+		return t.LogError(ctx, "Tx session variable", err, slog.String("query", query))
+	}
+	t.Log(ctx, "Tx session variable set", slog.String("query", query), slog.String("value", value))
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"entgo.io/ent/dialect"
+)
+
+// sessionDriver is a fake driver of a dialect recording the statements of its transactions.
+type sessionDriver struct {
+	*fakeDriver
+	dialect    string
+	statements []string
+	args       []any
+	rollbacks  int
+}
+
+func (d *sessionDriver) Dialect() string { return d.dialect }
+
+func (d *sessionDriver) Tx(context.Context) (dialect.Tx, error) {
+	return &sessionTx{d}, nil
+}
+
+// sessionTx is a transaction of the session driver.
+type sessionTx struct {
+	*sessionDriver
+}
+
+func (tx *sessionTx) Exec(ctx context.Context, query string, args, v any) error {
+	tx.statements = append(tx.statements, query)
+	tx.args = append(tx.args, args)
+	return tx.fakeDriver.Exec(ctx, query, args, v)
+}
+
+func (*sessionTx) Commit() error { return nil }
+
+func (tx *sessionTx) Rollback() error {
+	tx.rollbacks++
+	return nil
+}
+
+func TestSessionTagging(t *testing.T) {
+	tests := []struct {
+		name      string
+		dialect   string
+		tag       SessionTagFunc
+		statement string
+		value     string // expected value, empty for the transaction id.
+	}{
+		{"postgres", dialect.Postgres, nil, "SELECT set_config('application_name', $1, true)", ""},
+		{"mysql", dialect.MySQL, nil, "SET @request_id = ?", ""},
+		{"mysql request id", dialect.MySQL, func(context.Context) string { return "req-1" }, "SET @request_id = ?", "req-1"},
+		{"sqlite", dialect.SQLite, nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log testLog
+			dri := &sessionDriver{fakeDriver: &fakeDriver{clock: newTestClock()}, dialect: tt.dialect}
+			drv := New(dri, WithLogger(log.Logger()), WithSessionTagging(tt.tag))
+			tx, err := drv.Tx(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			id := tx.(*SlogTx).id
+			if tt.statement == "" {
+				if len(dri.statements) != 0 || len(log.Records(t, "Tx session variable set")) != 0 {
+					t.Errorf("session statements %v", dri.statements)
+				}
+				return
+			}
+			want := tt.value
+			if want == "" {
+				want = id
+			}
+			if len(dri.statements) != 1 || dri.statements[0] != tt.statement {
+				t.Fatalf("session statements = %v, want %q", dri.statements, tt.statement)
+			}
+			if args, _ := dri.args[0].([]any); len(args) != 1 || args[0] != want {
+				t.Errorf("session args = %v, want %q", dri.args[0], want)
+			}
+			records := log.Records(t, "Tx session variable set")
+			if len(records) != 1 || records[0]["value"] != want || records[0]["id"] != id {
+				t.Errorf("session records = %v", records)
+			}
+		})
+	}
+}
+
+func TestSessionTaggingFailure(t *testing.T) {
+	var log testLog
+	dri := &sessionDriver{fakeDriver: &fakeDriver{clock: newTestClock(), err: errors.New("denied")}, dialect: dialect.Postgres}
+	drv := New(dri, WithLogger(log.Logger()), WithSessionTagging(nil))
+	if _, err := drv.Tx(context.Background()); err == nil || err.Error() != "denied" {
+		t.Fatalf("Tx = %v, want the session variable error", err)
+	}
+	if dri.rollbacks != 1 {
+		t.Errorf("got %d rollbacks, want 1", dri.rollbacks)
+	}
+	if records := log.Records(t, "Tx session variable"); len(records) != 1 || records[0]["error"] != "denied" {
+		t.Errorf("session error records = %v", records)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// txShedder caps the number of concurrent transactions whose statements are logged.
+type txShedder struct {
+	max    int64        // maximum number of concurrently logged transactions.
+	open   atomic.Int64 // transactions currently open.
+	logged atomic.Int64 // transactions currently logged.
+}
+
+// admit registers a new transaction and reports whether its statements are logged. While
+// more transactions than the cap are open, the logged ones are chosen by the hash of their
+// id, so the same transactions are logged by every replica sharing trace ids.
+func (s *txShedder) admit(id string) bool {
+	open := s.open.Add(1)
+	if open > s.max {
+		h := fnv.New32a()
+		h.Write([]byte(id))
+		if int64(h.Sum32())%open >= s.max {
+			return false
+		}
+	}
+	for {
+		logged := s.logged.Load()
+		if logged >= s.max {
+			return false
+		}
+		if s.logged.CompareAndSwap(logged, logged+1) {
+			return true
+		}
+	}
+}
+
+// release unregisters a transaction admitted with the given outcome.
+func (s *txShedder) release(logged bool) {
+	s.open.Add(-1)
+	if logged {
+		s.logged.Add(-1)
+	}
+}
+
+// txSummary accumulates the statements of a transaction whose statements are not logged.
+type txSummary struct {
+	start      time.Time
+	statements atomic.Int64
+	errors     atomic.Int64
+}
+
+func (s *txSummary) observe(err error) {
+	s.statements.Add(1)
+	if err != nil {
+		s.errors.Add(1)
+	}
+}
+
+// endShed releases the transaction slot and, when the statements of the transaction were
+// not logged, logs its summary. Errors and warnings are logged regardless of shedding.
+func (d *SlogTx) endShed(ctx context.Context, outcome string) {
+	if d.shedder == nil || !d.released.CompareAndSwap(false, true) {
+		return
+	}
+	d.shedder.release(d.txSummary == nil)
+	if d.txSummary == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("outcome", outcome),
+		slog.Int64("statements", d.txSummary.statements.Load()),
+		slog.Int64("errors", d.txSummary.errors.Load()),
+		slog.Duration("duration", d.now().Sub(d.txSummary.start)),
+		slog.Bool("shed", true),
+	}
+	d.logAt(ctx, d.level.Level(), "Tx summary", attrs...)
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// SLO describes a latency service level objective: at least Objective of the statements
+// must complete within Target. The ratio between the observed share of slow statements
+// and the error budget (1 - Objective) is the burn rate.
+type SLO struct {
+	Target     time.Duration // Target is the latency a statement must not exceed.
+	Objective  float64       // Objective is the share of statements that must meet Target, e.g. 0.99.
+	Window     time.Duration // Window is the period over which the burn rate is evaluated, defaults to one minute.
+	BurnRate   float64       // BurnRate is the burn rate above which a warning is logged, defaults to 1.
+	MinSamples int           // MinSamples is the number of statements required in a window before warning, defaults to 10.
+}
+
+// withDefaults returns a copy of s with unset fields replaced by their defaults.
+func (s SLO) withDefaults() SLO {
+	if s.Window <= 0 {
+		s.Window = time.Minute
+	}
+	if s.BurnRate <= 0 {
+		s.BurnRate = 1
+	}
+	if s.MinSamples <= 0 {
+		s.MinSamples = 10
+	}
+	if s.Objective <= 0 || s.Objective >= 1 {
+		s.Objective = 0.99
+	}
+	return s
+}
+
+// sloTracker accumulates the statements of one SLO over its current window.
+type sloTracker struct {
+	SLO
+	fingerprint string // fingerprint the objective applies to, empty for the global objective.
+
+	mu     sync.Mutex
+	start  time.Time // beginning of the current window.
+	total  int       // statements observed in the current window.
+	slow   int       // statements exceeding the target in the current window.
+	warned bool      // whether the current window has already been reported.
+}
+
+// observe records a statement and returns the burn rate when it crosses the threshold
+// for the first time in the current window.
+func (t *sloTracker) observe(now time.Time, d time.Duration) (burn float64, total, slow int, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.start) >= t.Window {
+		t.start, t.total, t.slow, t.warned = now, 0, 0, false
+	}
+	t.total++
+	if d > t.Target {
+		t.slow++
+	}
+	if t.warned || t.total < t.MinSamples {
+		return 0, 0, 0, false
+	}
+	burn = (float64(t.slow) / float64(t.total)) / (1 - t.Objective)
+	if burn < t.BurnRate {
+		return 0, 0, 0, false
+	}
+	t.warned = true
+	return burn, t.total, t.slow, true
+}
+
+// sloSet holds the global objective and the per-fingerprint objectives.
+type sloSet struct {
+	global *sloTracker
+	byKey  map[string]*sloTracker
+}
+
+func (s *sloSet) add(slo SLO, queries []string) {
+	slo = slo.withDefaults()
+	if len(queries) == 0 {
+		s.global = &sloTracker{SLO: slo}
+		return
+	}
+	if s.byKey == nil {
+		s.byKey = make(map[string]*sloTracker, len(queries))
+	}
+	for _, query := range queries {
+		key := fingerprint(query)
+		s.byKey[key] = &sloTracker{SLO: slo, fingerprint: key}
+	}
+}
+
+// clone returns a set with the same objectives and fresh windows.
+func (s *sloSet) clone() *sloSet {
+	c := &sloSet{}
+	if s.global != nil {
+		c.global = &sloTracker{SLO: s.global.SLO}
+	}
+	if s.byKey != nil {
+		c.byKey = make(map[string]*sloTracker, len(s.byKey))
+		for key, t := range s.byKey {
+			c.byKey[key] = &sloTracker{SLO: t.SLO, fingerprint: t.fingerprint}
+		}
+	}
+	return c
+}
+
+// inherit takes over the trackers of prev whose objective is unchanged, keeping their
+// current window.
+func (s *sloSet) inherit(prev *sloSet) {
+	if s.global != nil && prev.global != nil && s.global.SLO == prev.global.SLO {
+		s.global = prev.global
+	}
+	for key, t := range s.byKey {
+		if p, ok := prev.byKey[key]; ok && p.SLO == t.SLO {
+			s.byKey[key] = p
+		}
+	}
+}
+
+// observeSLO feeds the completed statement to the matching objectives and warns on excessive burn.
+func (op *operation) observeSLO() {
+	if op.slos == nil {
+		return
+	}
+	now := op.now()
+	if t := op.slos.global; t != nil {
+		op.checkSLO(op.ctx, t, now, op.took)
+	}
+	if len(op.slos.byKey) > 0 {
+		if t, ok := op.slos.byKey[op.fingerprint()]; ok {
+			op.checkSLO(op.ctx, t, now, op.took)
+		}
+	}
+}
+
+func (h *Handler) checkSLO(ctx context.Context, t *sloTracker, now time.Time, d time.Duration) {
+	burn, total, slow, ok := t.observe(now, d)
+	if !ok {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.Float64("burn_rate", burn),
+		slog.Duration("target", t.Target),
+		slog.Float64("objective", t.Objective),
+		slog.Duration("window", t.Window),
+		slog.Int("total", total),
+		slog.Int("slow", slow),
+	}
+	if t.fingerprint != "" {
+		attrs = append(attrs, slog.String("fingerprint", t.fingerprint))
+	}
+	h.logAt(ctx, slog.LevelWarn, "SLO burn rate exceeded", attrs...)
+}
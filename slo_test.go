@@ -0,0 +1,108 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const sloWarning = "SLO burn rate exceeded"
+
+func TestLatencySLO(t *testing.T) {
+	ctx := context.Background()
+	var log testLog
+	fake := &fakeDriver{clock: newTestClock()}
+	slo := SLO{Target: 100 * time.Millisecond, Objective: 0.9, Window: time.Minute, MinSamples: 4}
+	drv := newTestDriver(fake, &log, WithLatencySLO(slo))
+	exec := func(took time.Duration) {
+		t.Helper()
+		fake.took = took
+		if err := drv.Exec(ctx, "UPDATE t SET a = 1", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Below MinSamples nothing is reported, even if every statement is slow.
+	for range 3 {
+		exec(200 * time.Millisecond)
+	}
+	if records := log.Records(t, sloWarning); len(records) != 0 {
+		t.Fatalf("got %d warnings before MinSamples, want 0", len(records))
+	}
+	exec(200 * time.Millisecond)
+	records := log.Records(t, sloWarning)
+	if len(records) != 1 {
+		t.Fatalf("got %d warnings, want 1", len(records))
+	}
+	if record := records[0]; record["level"] != "WARN" || record["total"] != 4.0 || record["slow"] != 4.0 {
+		t.Errorf("warning = %v", record)
+	}
+
+	// A window is reported once.
+	exec(200 * time.Millisecond)
+	if records := log.Records(t, sloWarning); len(records) != 1 {
+		t.Fatalf("got %d warnings in the same window, want 1", len(records))
+	}
+
+	// The next window starts over.
+	fake.clock.Advance(time.Minute)
+	for range 3 {
+		exec(200 * time.Millisecond)
+	}
+	if records := log.Records(t, sloWarning); len(records) != 1 {
+		t.Fatalf("got %d warnings after the window reset, want 1", len(records))
+	}
+	exec(200 * time.Millisecond)
+	if records := log.Records(t, sloWarning); len(records) != 2 {
+		t.Fatalf("got %d warnings in the next window, want 2", len(records))
+	}
+}
+
+func TestLatencySLOWithinBudget(t *testing.T) {
+	ctx := context.Background()
+	var log testLog
+	fake := &fakeDriver{clock: newTestClock()}
+	slo := SLO{Target: 100 * time.Millisecond, Objective: 0.5, MinSamples: 4}
+	drv := newTestDriver(fake, &log, WithLatencySLO(slo))
+	// One slow statement out of four burns half of the budget.
+	for i, took := range []time.Duration{200, 10, 10, 10} {
+		fake.took = took * time.Millisecond
+		if err := drv.Exec(ctx, "UPDATE t SET a = ?", []any{i}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if records := log.Records(t, sloWarning); len(records) != 0 {
+		t.Errorf("got %d warnings within the error budget, want 0", len(records))
+	}
+}
+
+func TestLatencySLOQueries(t *testing.T) {
+	ctx := context.Background()
+	var log testLog
+	fake := &fakeDriver{clock: newTestClock(), took: time.Second}
+	slo := SLO{Target: 100 * time.Millisecond, MinSamples: 2}
+	drv := newTestDriver(fake, &log, WithLatencySLO(slo, "SELECT * FROM users WHERE id = 1"))
+	for _, query := range []string{"SELECT * FROM posts WHERE id = 1", "SELECT * FROM posts WHERE id = 2"} {
+		if err := drv.Query(ctx, query, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if records := log.Records(t, sloWarning); len(records) != 0 {
+		t.Fatalf("got %d warnings for other queries, want 0", len(records))
+	}
+	for _, query := range []string{"SELECT * FROM users WHERE id = 2", "select * from users where id = 3"} {
+		if err := drv.Query(ctx, query, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := log.Records(t, sloWarning)
+	if len(records) != 1 {
+		t.Fatalf("got %d warnings, want 1", len(records))
+	}
+	if got, want := records[0]["fingerprint"], fingerprint("SELECT * FROM users WHERE id = ?"); got != want {
+		t.Errorf("fingerprint = %v, want %q", got, want)
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// truncationMarker ends the truncated queries of records.
+const truncationMarker = "..."
+
+// tokenKind classifies a lexical token of a SQL statement.
+type tokenKind int
+
+const (
+	tokWord    tokenKind = iota // keyword or bare identifier.
+	tokQuoted                   // quoted identifier, "name", `name` or [name].
+	tokString                   // string literal, 'value'.
+	tokNumber                   // numeric literal.
+	tokParam                    // bind placeholder, ?, $1, :name or @name.
+	tokPunct                    // any other single character.
+	tokSpace                    // run of whitespace.
+	tokComment                  // -- line or /* block */ comment.
+)
+
+// token is a lexical token of a SQL statement.
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// scanSQL splits query into tokens. It is a lightweight lexer meant for logging
+// purposes: it understands quoting and comments well enough to never mistake
+// a literal for an identifier, but it does not validate the statement.
+func scanSQL(query string) []token {
+	var tokens []token
+	for i := 0; i < len(query); {
+		c := query[i]
+		start := i
+		kind := tokPunct
+		switch {
+		case isSpace(c):
+			kind = tokSpace
+			for i < len(query) && isSpace(query[i]) {
+				i++
+			}
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			kind = tokComment
+			if n := strings.IndexByte(query[i:], '\n'); n >= 0 {
+				i += n
+			} else {
+				i = len(query)
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			kind = tokComment
+			if n := strings.Index(query[i+2:], "*/"); n >= 0 {
+				i += n + 4
+			} else {
+				i = len(query)
+			}
+		case c == '\'':
+			kind = tokString
+			i = scanQuoted(query, i, '\'')
+		case c == '"' || c == '`':
+			kind = tokQuoted
+			i = scanQuoted(query, i, c)
+		case c == '[':
+			kind = tokQuoted
+			i = scanQuoted(query, i, ']')
+		case isDigit(c) || (c == '.' && i+1 < len(query) && isDigit(query[i+1])):
+			kind = tokNumber
+			for i < len(query) && (isWord(query[i]) || query[i] == '.') {
+				i++
+			}
+		case c == '$' && dollarTag(query[i:]) != "":
+			kind = tokString
+			tag := dollarTag(query[i:])
+			if n := strings.Index(query[i+len(tag):], tag); n >= 0 {
+				i += len(tag) + n + len(tag)
+			} else {
+				i = len(query)
+			}
+		case c == '?':
+			kind = tokParam
+			i++
+		case (c == '$' || c == ':' || c == '@') && i+1 < len(query) && isWord(query[i+1]) && !strings.HasSuffix(query[:i], ":"):
+			kind = tokParam
+			i++
+			for i < len(query) && isWord(query[i]) {
+				i++
+			}
+		case isWord(c):
+			kind = tokWord
+			for i < len(query) && (isWord(query[i]) || query[i] == '$') {
+				i++
+			}
+		default:
+			i++
+		}
+		tokens = append(tokens, token{kind: kind, text: query[start:i]})
+	}
+	return tokens
+}
+
+// dollarTag returns the opening tag of the Postgres dollar-quoted string, $$ or $tag$,
+// starting s, or an empty string when s does not start with one.
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$':
+			return s[:i+1]
+		case !isWord(c) || (i == 1 && isDigit(c)):
+			return ""
+		}
+	}
+	return ""
+}
+
+// scanQuoted returns the index just past the quoted section starting at i.
+// A doubled closing quote is treated as an escaped quote.
+func scanQuoted(query string, i int, closing byte) int {
+	for i++; i < len(query); i++ {
+		if query[i] != closing {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == closing && closing != ']' {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWord(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+// unquote strips identifier quotes from a token.
+func unquote(t token) string {
+	if t.kind == tokQuoted && len(t.text) >= 2 {
+		return t.text[1 : len(t.text)-1]
+	}
+	return strings.ToLower(t.text)
+}
+
+// tableKeywords are the keywords that are followed by a table name.
+var tableKeywords = map[string]bool{"FROM": true, "JOIN": true, "INTO": true, "UPDATE": true, "TABLE": true}
+
+// tableModifiers may appear between a table keyword and the table name.
+var tableModifiers = map[string]bool{"IF": true, "NOT": true, "EXISTS": true, "ONLY": true}
+
+// tablesIn returns the names of the tables referenced by the significant tokens of a
+// query, in order of appearance and without duplicates. Schema-qualified names keep
+// their qualifier.
+func tablesIn(tokens []token) []string {
+	var tables []string
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].kind != tokWord || !tableKeywords[strings.ToUpper(tokens[i].text)] {
+			continue
+		}
+		j := i + 1
+		for j < len(tokens) && tokens[j].kind == tokWord && tableModifiers[strings.ToUpper(tokens[j].text)] {
+			j++
+		}
+		if j >= len(tokens) || (tokens[j].kind != tokWord && tokens[j].kind != tokQuoted) {
+			continue
+		}
+		name := unquote(tokens[j])
+		for j+2 < len(tokens) && tokens[j+1].text == "." && (tokens[j+2].kind == tokWord || tokens[j+2].kind == tokQuoted) {
+			name += "." + unquote(tokens[j+2])
+			j += 2
+		}
+		if !slices.Contains(tables, name) {
+			tables = append(tables, name)
+		}
+		i = j
+	}
+	return tables
+}
+
+// significant drops whitespace and comment tokens.
+func significant(tokens []token) []token {
+	out := tokens[:0:0]
+	for _, t := range tokens {
+		if t.kind != tokSpace && t.kind != tokComment {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// scrubLiterals replaces string and numeric literals in query with a ? placeholder.
+func scrubLiterals(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	for _, t := range scanSQL(query) {
+		if t.kind == tokString || t.kind == tokNumber {
+			b.WriteByte('?')
+			continue
+		}
+		b.WriteString(t.text)
+	}
+	return b.String()
+}
+
+// truncate returns the first n bytes of s, shortened further to not split a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// collapseSpace reduces every run of whitespace outside literals to a single space and
+// trims the query, turning multi-line statements into single-line ones. Line comments
+// become block comments so that they do not swallow the rest of the statement.
+func collapseSpace(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	for _, t := range scanSQL(query) {
+		switch {
+		case t.kind == tokSpace:
+			if b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+		case t.kind == tokComment && strings.HasPrefix(t.text, "--"):
+			if text := strings.TrimSpace(t.text[2:]); !strings.Contains(text, "*/") {
+				b.WriteString("/* " + text + " */")
+			}
+		default:
+			b.WriteString(t.text)
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+// fingerprint normalizes query so that statements differing only in their literals,
+// placeholders, IN-list lengths or formatting share the same value.
+func fingerprint(query string) string {
+	return fingerprintOf(significant(scanSQL(query)))
+}
+
+// fingerprintOf is fingerprint for the significant tokens of a query.
+func fingerprintOf(tokens []token) string {
+	var b strings.Builder
+	var prev token
+	for i, t := range collapseLists(tokens) {
+		if i > 0 && needsSpace(prev, t) {
+			b.WriteByte(' ')
+		}
+		switch t.kind {
+		case tokString, tokNumber, tokParam:
+			b.WriteByte('?')
+		case tokWord:
+			b.WriteString(strings.ToUpper(t.text))
+		default:
+			b.WriteString(t.text)
+		}
+		prev = t
+	}
+	return b.String()
+}
+
+// queryHash returns a short stable hash of a fingerprint, for grouping records
+// without the query text.
+func queryHash(fingerprint string) string {
+	h := fnv.New64a()
+	h.Write([]byte(fingerprint))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// collapseLists replaces the value lists of IN predicates with a single placeholder,
+// so that IN (?, ?, ?) and IN (?) are fingerprinted alike.
+func collapseLists(tokens []token) []token {
+	out := tokens[:0:0]
+	for i := 0; i < len(tokens); i++ {
+		out = append(out, tokens[i])
+		if tokens[i].kind != tokWord || !strings.EqualFold(tokens[i].text, "IN") {
+			continue
+		}
+		if end := valueList(tokens, i+1); end > 0 {
+			out = append(out, tokens[i+1], token{kind: tokParam, text: "?"}, tokens[end])
+			i = end
+		}
+	}
+	return out
+}
+
+// valueList returns the index of the closing parenthesis of the list of literals and
+// placeholders opening at tokens[i], or 0 when there is no such list.
+func valueList(tokens []token, i int) int {
+	if i >= len(tokens) || tokens[i].text != "(" {
+		return 0
+	}
+	values := 0
+	for j := i + 1; j < len(tokens); j++ {
+		switch t := tokens[j]; {
+		case t.kind == tokString || t.kind == tokNumber || t.kind == tokParam:
+			values++
+		case t.text == "," || t.text == "-":
+		case t.text == ")" && values > 0:
+			return j
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// needsSpace reports whether a separating space is kept between prev and t in a fingerprint.
+func needsSpace(prev, t token) bool {
+	if prev.kind == tokPunct && (prev.text == "(" || prev.text == ".") {
+		return false
+	}
+	return t.kind != tokPunct || (t.text != "," && t.text != ")" && t.text != ".")
+}
+
+// Statement classes reported by the op attribute.
+const (
+	ClassSelect = "SELECT"
+	ClassInsert = "INSERT"
+	ClassUpdate = "UPDATE"
+	ClassDelete = "DELETE"
+	ClassDDL    = "DDL"
+	ClassOther  = "OTHER"
+)
+
+// classOf returns the class of a query from the leading keyword of its significant tokens.
+// Common table expressions are classified by the first statement keyword following them.
+func classOf(tokens []token) string {
+	i := 0
+	for i < len(tokens) && tokens[i].text == "(" {
+		i++
+	}
+	if i == len(tokens) || tokens[i].kind != tokWord {
+		return ClassOther
+	}
+	if kw := strings.ToUpper(tokens[i].text); kw != "WITH" {
+		return keywordClass(kw)
+	}
+	depth := 0
+	for _, t := range tokens[i+1:] {
+		switch {
+		case t.text == "(":
+			depth++
+		case t.text == ")":
+			depth--
+		case depth == 0 && t.kind == tokWord:
+			if class := keywordClass(strings.ToUpper(t.text)); class != ClassOther {
+				return class
+			}
+		}
+	}
+	return ClassOther
+}
+
+// keywordClass returns the statement class introduced by keyword.
+func keywordClass(keyword string) string {
+	switch keyword {
+	case "SELECT", "VALUES", "TABLE":
+		return ClassSelect
+	case "INSERT", "REPLACE", "UPSERT":
+		return ClassInsert
+	case "UPDATE":
+		return ClassUpdate
+	case "DELETE":
+		return ClassDelete
+	case "CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME", "COMMENT":
+		return ClassDDL
+	}
+	return ClassOther
+}
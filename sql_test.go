@@ -0,0 +1,102 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"literals", "SELECT * FROM t WHERE id = 1 AND name = 'x'", "SELECT * FROM T WHERE ID = ? AND NAME = ?"},
+		{"placeholders", "SELECT * FROM t WHERE a = ? AND b = $2", "SELECT * FROM T WHERE A = ? AND B = ?"},
+		{"formatting", "select  *\n\tfrom t -- comment\n where id = 1", "SELECT * FROM T WHERE ID = ?"},
+		{"in list", "SELECT * FROM t WHERE id IN (1, 2, 3)", "SELECT * FROM T WHERE ID IN (?)"},
+		{"in placeholders", "SELECT * FROM t WHERE id IN ($1,$2) AND b in (?)", "SELECT * FROM T WHERE ID IN (?) AND B IN (?)"},
+		{"in negative numbers", "SELECT * FROM t WHERE id IN (-1, 2)", "SELECT * FROM T WHERE ID IN (?)"},
+		{"in subquery", "SELECT * FROM t WHERE id IN (SELECT id FROM u)", "SELECT * FROM T WHERE ID IN (SELECT ID FROM U)"},
+		{"values rows", "INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y')", "INSERT INTO T (A, B) VALUES (?, ?), (?, ?)"},
+		{"qualified names", "SELECT a.b FROM s.t", "SELECT A.B FROM S.T"},
+		{"semicolon in literal", "SELECT ';' FROM t", "SELECT ? FROM T"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fingerprint(tt.query); got != tt.want {
+				t.Errorf("fingerprint(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFingerprintInListLengths(t *testing.T) {
+	short := fingerprint("SELECT * FROM t WHERE id IN (?)")
+	for _, query := range []string{
+		"SELECT * FROM t WHERE id IN (?, ?)",
+		"SELECT * FROM t WHERE id IN (1, 2, 3, 4, 5)",
+		"SELECT * FROM t WHERE id IN ('a','b')",
+	} {
+		if got := fingerprint(query); got != short {
+			t.Errorf("fingerprint(%q) = %q, want %q", query, got, short)
+		}
+	}
+}
+
+func TestCollapseLists(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"values", "id IN (1, 2, 3)", []string{"id", "IN", "(", "?", ")"}},
+		{"lower case", "id in (?, ?)", []string{"id", "in", "(", "?", ")"}},
+		{"empty list", "id IN ()", []string{"id", "IN", "(", ")"}},
+		{"subquery", "id IN (SELECT 1)", []string{"id", "IN", "(", "SELECT", "1", ")"}},
+		{"column list", "id IN (a, b)", []string{"id", "IN", "(", "a", ",", "b", ")"}},
+		{"unterminated", "id IN (1, 2", []string{"id", "IN", "(", "1", ",", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, tok := range collapseLists(significant(scanSQL(tt.query))) {
+				got = append(got, tok.text)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("collapseLists(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT 1", ClassSelect},
+		{"(SELECT 1) UNION (SELECT 2)", ClassSelect},
+		{"insert into t values (1)", ClassInsert},
+		{"UPDATE t SET a = 1", ClassUpdate},
+		{"DELETE FROM t", ClassDelete},
+		{"/* comment */ CREATE TABLE t (a int)", ClassDDL},
+		{"-- comment\nALTER TABLE t ADD b int", ClassDDL},
+		{"WITH x AS (SELECT 1) DELETE FROM t WHERE id IN (SELECT * FROM x)", ClassDelete},
+		{"WITH RECURSIVE r(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM r) SELECT * FROM r", ClassSelect},
+		{"WITH a AS (INSERT INTO t VALUES (1) RETURNING id) SELECT * FROM a", ClassSelect},
+		{"WITH a AS (SELECT 1), b AS (SELECT 2) UPDATE t SET a = 1", ClassUpdate},
+		{"WITH x AS MATERIALIZED (SELECT 1) INSERT INTO t SELECT * FROM x", ClassInsert},
+		{"WITH x AS (SELECT 1)", ClassOther},
+		{"BEGIN", ClassOther},
+		{"", ClassOther},
+	}
+	for _, tt := range tests {
+		if got := classOf(significant(scanSQL(tt.query))); got != tt.want {
+			t.Errorf("classOf(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"fmt"
+	"reflect"
+
+	"entgo.io/ent/dialect"
+	"github.com/goexts/generic/settings"
+)
+
+// maxStackDepth bounds the walk of DescribeStack in case of cyclic wrappers.
+const maxStackDepth = 32
+
+var driverType = reflect.TypeOf((*dialect.Driver)(nil)).Elem()
+
+// DescribeStack returns the types of the drivers making up the wrapper stack of drv,
+// outermost first, e.g. ["*entcache.Driver" "*entslog.SlogDriver" "*sql.Driver"].
+// Wrappers are unwrapped through an `Unwrap() dialect.Driver` method or, failing that,
+// through an embedded dialect.Driver field as used by dialect.DebugDriver.
+func DescribeStack(drv dialect.Driver) []string {
+	var stack []string
+	for drv != nil && len(stack) < maxStackDepth {
+		stack = append(stack, fmt.Sprintf("%T", drv))
+		drv = unwrapDriver(drv)
+	}
+	return stack
+}
+
+// InnermostDriver returns the driver at the bottom of the wrapper stack of drv, such as
+// the *sql.Driver of entgo.io/ent/dialect/sql, for code asserting its concrete type, e.g.
+// to reach the underlying *sql.DB or to run migrations.
+func InnermostDriver(drv dialect.Driver) dialect.Driver {
+	for depth := 0; depth < maxStackDepth; depth++ {
+		inner := unwrapDriver(drv)
+		if inner == nil {
+			break
+		}
+		drv = inner
+	}
+	return drv
+}
+
+// unwrapDriver returns the driver wrapped by drv, or nil when drv is not a known wrapper.
+func unwrapDriver(drv dialect.Driver) dialect.Driver {
+	if d, ok := drv.(interface{ Unwrap() dialect.Driver }); ok {
+		return d.Unwrap()
+	}
+	v := reflect.ValueOf(drv)
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.Anonymous && f.Type == driverType && f.IsExported() && !v.Field(i).IsNil() {
+			inner, _ := v.Field(i).Interface().(dialect.Driver)
+			return inner
+		}
+	}
+	return nil
+}
+
+// Wrap is like New, but stacks cleanly on top of other wrappers of drv:
+//
+//   - when drv is a SlogDriver already, ss are applied to it with Reconfigure and drv is
+//     returned, instead of logging every operation twice;
+//   - the dialect.DebugDriver wrappers on top of drv are removed, as entslog logs the
+//     operations they would log;
+//   - when the remaining wrappers hide the ExecContext, QueryContext or BeginTx methods of
+//     the innermost driver, those calls are sent to the innermost driver directly,
+//     bypassing the wrappers in between, instead of failing with ErrUnsupported.
+func Wrap(drv dialect.Driver, ss ...Setting) dialect.Driver {
+	if d, ok := drv.(*SlogDriver); ok {
+		d.Reconfigure(ss...)
+		return d
+	}
+	for {
+		debug, ok := drv.(*dialect.DebugDriver)
+		if !ok || debug.Driver == nil {
+			break
+		}
+		drv = debug.Driver
+	}
+	opt := defaultOption
+	d := newDriver(drv, settings.Apply(&opt, ss))
+	if inner := InnermostDriver(drv); inner != drv {
+		d.exposed = inner
+	}
+	return d
+}
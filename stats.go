@@ -0,0 +1,214 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"math"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+type (
+	// Stats is a snapshot of the operations performed by a driver since it was created.
+	Stats struct {
+		Since      time.Time                 // Since is the time the driver, or the first driver of a StatsStore, was created.
+		Operations map[string]OperationStats // Operations holds the statistics by operation: exec, query, tx, commit or rollback.
+		Queries    map[string]OperationStats // Queries holds the statistics by query fingerprint, only kept with WithStatsStore.
+	}
+	// OperationStats holds the cumulative statistics of one operation kind.
+	// Percentiles are approximated with a resolution of about 19%.
+	OperationStats struct {
+		Count  uint64        // Count is the number of operations.
+		Errors uint64        // Errors is the number of operations that returned an error.
+		Total  time.Duration // Total is the cumulated duration of the operations.
+		Min    time.Duration // Min is the shortest duration observed.
+		Max    time.Duration // Max is the longest duration observed.
+		P50    time.Duration // P50 is the median duration.
+		P90    time.Duration // P90 is the 90th percentile duration.
+		P99    time.Duration // P99 is the 99th percentile duration.
+	}
+)
+
+const (
+	// histogramBuckets is the number of latency buckets: four per power of two of nanoseconds.
+	histogramBuckets = 64 * 4
+	// maxTrackedQueries bounds the number of distinct fingerprints tracked by a recorder.
+	maxTrackedQueries = 1000
+	// otherQueries collects the statements beyond maxTrackedQueries fingerprints.
+	otherQueries = "(other queries)"
+)
+
+// opRecorder accumulates the statistics of one operation kind.
+type opRecorder struct {
+	count, errors uint64
+	total         time.Duration
+	min, max      time.Duration
+	buckets       [histogramBuckets]uint64
+}
+
+// bucketOf returns the histogram bucket of d.
+func bucketOf(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	n := uint64(d)
+	exp := bits.Len64(n) - 1
+	var frac uint64
+	if exp >= 2 {
+		frac = (n >> (exp - 2)) & 3
+	} else {
+		frac = (n << (2 - exp)) & 3
+	}
+	return exp*4 + int(frac)
+}
+
+// bucketUpper returns the upper bound of bucket i.
+func bucketUpper(i int) time.Duration {
+	exp, frac := i/4, i%4
+	return time.Duration(math.Ldexp(1+float64(frac+1)/4, exp))
+}
+
+func (r *opRecorder) observe(d time.Duration, failed bool) {
+	r.count++
+	if failed {
+		r.errors++
+	}
+	r.total += d
+	if r.count == 1 || d < r.min {
+		r.min = d
+	}
+	if d > r.max {
+		r.max = d
+	}
+	r.buckets[bucketOf(d)]++
+}
+
+func (r *opRecorder) percentile(p float64) time.Duration {
+	rank := uint64(math.Ceil(p * float64(r.count)))
+	var seen uint64
+	for i, n := range r.buckets {
+		seen += n
+		if seen >= rank {
+			return min(max(bucketUpper(i), r.min), r.max)
+		}
+	}
+	return r.max
+}
+
+func (r *opRecorder) snapshot() OperationStats {
+	return OperationStats{
+		Count:  r.count,
+		Errors: r.errors,
+		Total:  r.total,
+		Min:    r.min,
+		Max:    r.max,
+		P50:    r.percentile(0.50),
+		P90:    r.percentile(0.90),
+		P99:    r.percentile(0.99),
+	}
+}
+
+// seed restores the statistics of a snapshot. The histogram is rebuilt from the
+// percentiles, so percentiles combining seeded and new durations are approximate.
+func (r *opRecorder) seed(s OperationStats) {
+	r.count, r.errors = s.Count, s.Errors
+	r.total, r.min, r.max = s.Total, s.Min, s.Max
+	rest := s.Count
+	for _, q := range []struct {
+		d     time.Duration
+		share float64
+	}{{s.P50, 0.50}, {s.P90, 0.40}, {s.P99, 0.09}} {
+		n := min(uint64(q.share*float64(s.Count)), rest)
+		r.buckets[bucketOf(q.d)] += n
+		rest -= n
+	}
+	r.buckets[bucketOf(s.Max)] += rest
+}
+
+// recorderOf returns the recorder of key in m, adding it when missing.
+func recorderOf(m map[string]*opRecorder, key string) *opRecorder {
+	rec, ok := m[key]
+	if !ok {
+		rec = new(opRecorder)
+		m[key] = rec
+	}
+	return rec
+}
+
+// queryKey returns the key of fingerprint fp in m, which is otherQueries once m tracks
+// maxTrackedQueries other fingerprints.
+func queryKey(m map[string]*opRecorder, fp string) string {
+	if _, ok := m[fp]; !ok && len(m) >= maxTrackedQueries {
+		return otherQueries
+	}
+	return fp
+}
+
+// statsRecorder is an Observer accumulating the statistics returned by SlogDriver.Stats.
+type statsRecorder struct {
+	since   time.Time
+	mu      sync.Mutex
+	ops     map[string]*opRecorder
+	queries map[string]*opRecorder // by fingerprint, nil unless statistics are stored.
+}
+
+// newStatsRecorder returns a recorder, which also keeps statistics by query fingerprint when byQuery is set.
+func newStatsRecorder(byQuery bool) *statsRecorder {
+	s := &statsRecorder{since: time.Now(), ops: make(map[string]*opRecorder)}
+	if byQuery {
+		s.queries = make(map[string]*opRecorder)
+	}
+	return s
+}
+
+// Observe implements Observer.
+func (s *statsRecorder) Observe(_ context.Context, e Event) {
+	name := counterName(e.Op)
+	var fp string
+	if s.queries != nil {
+		fp = e.Fingerprint
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recorderOf(s.ops, name).observe(e.Duration, e.Err != nil)
+	if fp != "" {
+		recorderOf(s.queries, queryKey(s.queries, fp)).observe(e.Duration, e.Err != nil)
+	}
+}
+
+func (s *statsRecorder) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := Stats{Since: s.since, Operations: make(map[string]OperationStats, len(s.ops))}
+	for name, r := range s.ops {
+		stats.Operations[name] = r.snapshot()
+	}
+	if s.queries != nil {
+		stats.Queries = make(map[string]OperationStats, len(s.queries))
+		for fp, r := range s.queries {
+			stats.Queries[fp] = r.snapshot()
+		}
+	}
+	return stats
+}
+
+// restore seeds the recorder with a snapshot saved by a previous process.
+func (s *statsRecorder) restore(stats Stats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !stats.Since.IsZero() {
+		s.since = stats.Since
+	}
+	for name, ops := range stats.Operations {
+		recorderOf(s.ops, name).seed(ops)
+	}
+	if s.queries == nil {
+		return
+	}
+	for fp, qs := range stats.Queries {
+		recorderOf(s.queries, fp).seed(qs)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// StatsStore persists the statistics of a driver, including those by query fingerprint,
+// across process restarts, so that query performance can be compared over weeks.
+type StatsStore interface {
+	// Save stores a snapshot of the statistics, replacing the previous one.
+	Save(stats Stats) error
+	// Load returns the last saved snapshot, or zero Stats when none was saved.
+	Load() (Stats, error)
+}
+
+// fileStatsStore is a StatsStore keeping the snapshot in a JSON file.
+type fileStatsStore struct {
+	path string
+}
+
+// NewFileStatsStore returns a StatsStore keeping the snapshot in the JSON file at path.
+// Snapshots are written to a temporary file first, then renamed over path.
+func NewFileStatsStore(path string) StatsStore {
+	return fileStatsStore{path: path}
+}
+
+func (s fileStatsStore) Save(stats Stats) error {
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if err = errors.Join(err, f.Close()); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+func (s fileStatsStore) Load() (Stats, error) {
+	var stats Stats
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return stats, err
+	}
+	return stats, json.Unmarshal(data, &stats)
+}
+
+// loadStats seeds the statistics of the driver with the snapshot of store.
+func (h *Handler) loadStats(store StatsStore) {
+	stats, err := store.Load()
+	if err != nil {
+		h.LogError(context.Background(), "stats load", err)
+		return
+	}
+	h.stats.restore(stats)
+}
+
+// saveStats saves a snapshot of the statistics of the driver to store.
+func (h *Handler) saveStats(store StatsStore) {
+	if err := store.Save(h.stats.snapshot()); err != nil {
+		h.LogError(context.Background(), "stats save", err)
+	}
+}
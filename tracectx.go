@@ -0,0 +1,55 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// traceRoot holds the trace id shared by the operations of a root context.
+type traceRoot struct {
+	once sync.Once
+	id   string
+}
+
+// traceRootKey is the context key under which ContextWithTrace stores the trace root.
+type traceRootKey struct{}
+
+// ContextWithTrace returns a copy of ctx, unless it is one already, that is the root of
+// a trace for WithContextTrace: the trace function of WithTrace is called once, for the
+// first operation under ctx or under contexts derived from it, and every operation under
+// it then carries the same trace id. The request id of EnsureRequestID, when the first
+// operation carries one, is the trace id instead. It is meant to be called once per
+// request, e.g. by an HTTP middleware.
+func ContextWithTrace(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(traceRootKey{}).(*traceRoot); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, traceRootKey{}, new(traceRoot))
+}
+
+// contextTrace returns the trace id of the root context of ctx, or, when it has no root,
+// its request id or the id returned by the trace function for ctx.
+func (h *Handler) contextTrace(ctx context.Context) string {
+	root, ok := ctx.Value(traceRootKey{}).(*traceRoot)
+	if !ok {
+		if id := RequestIDFromContext(ctx); id != "" {
+			return id
+		}
+		return h.trace(ctx)
+	}
+	root.once.Do(func() {
+		if root.id = RequestIDFromContext(ctx); root.id == "" {
+			root.id = h.trace(ctx)
+		}
+	})
+	return root.id
+}
+
+// traceAttr returns the trace_id attribute of the records logged under ctx.
+func (h *Handler) traceAttr(ctx context.Context) slog.Attr {
+	return slog.String("trace_id", h.contextTrace(ctx))
+}
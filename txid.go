@@ -0,0 +1,55 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+
+	"entgo.io/ent/dialect"
+)
+
+// txIDKey is the context key under which ContextWithTxID stores the transaction id.
+type txIDKey struct{}
+
+// ID returns the logging id of the transaction, carried by the `id` attribute of its records.
+func (d *SlogTx) ID() string {
+	return d.id
+}
+
+// ContextWithTxID returns a copy of ctx carrying the logging id of tx, so other
+// instrumentation, such as HTTP logs, spans or application logs, can correlate with the
+// records of the transaction through TxIDFromContext. tx is either a SlogTx or a
+// transaction wrapping one through an `Unwrap() dialect.Tx` method, ctx is returned
+// unchanged otherwise.
+func ContextWithTxID(ctx context.Context, tx dialect.Tx) context.Context {
+	for depth := 0; tx != nil && depth < maxStackDepth; depth++ {
+		if t, ok := tx.(*SlogTx); ok {
+			return context.WithValue(ctx, txIDKey{}, t.id)
+		}
+		u, ok := tx.(interface{ Unwrap() dialect.Tx })
+		if !ok {
+			break
+		}
+		tx = u.Unwrap()
+	}
+	return ctx
+}
+
+// TxIDFromContext returns the transaction id stored by ContextWithTxID or, under a
+// context of ContextWithTxTracking, the id of the latest transaction still open under
+// it, such as the transaction of an ent client begun with that context. It returns an
+// empty string when there is none.
+func TxIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(txIDKey{}).(string); ok {
+		return id
+	}
+	if t, ok := ctx.Value(txTrackerKey{}).(*txTracker); ok {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if len(t.open) > 0 {
+			return t.open[len(t.open)-1]
+		}
+	}
+	return ""
+}
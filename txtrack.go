@@ -0,0 +1,64 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// txTracker holds the ids of the transactions opened under a tracked context.
+type txTracker struct {
+	mu   sync.Mutex
+	open []string
+}
+
+// txTrackerKey is the context key under which ContextWithTxTracking stores the tracker.
+type txTrackerKey struct{}
+
+// ContextWithTxTracking returns a copy of ctx that records the transactions started under
+// it, or under contexts derived from it, until they are committed or rolled back. Starting
+// a transaction while another one is open under the same context then logs a warning with
+// both ids, catching accidental nested or parallel transactions of a single request, which
+// can deadlock a service with itself. It is meant to be called once per request, e.g. by
+// an HTTP middleware.
+func ContextWithTxTracking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, txTrackerKey{}, new(txTracker))
+}
+
+// trackTx records the transaction id opened under ctx and warns when other transactions
+// are still open under it. It returns nil when ctx is not tracked.
+func (h *Handler) trackTx(ctx context.Context, id string) *txTracker {
+	t, ok := ctx.Value(txTrackerKey{}).(*txTracker)
+	if !ok {
+		return nil
+	}
+	t.mu.Lock()
+	open := slices.Clone(t.open)
+	t.open = append(t.open, id)
+	t.mu.Unlock()
+	if len(open) > 0 {
+		h.logAt(ctx, slog.LevelWarn, "transaction started while another is open",
+			slog.String("id", id),
+			slog.String("open_id", open[len(open)-1]),
+			slog.Any("open_ids", open),
+			slog.Int("depth", len(open)),
+		)
+	}
+	return t
+}
+
+// done removes the transaction id from the tracker.
+func (t *txTracker) done(id string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if i := slices.Index(t.open, id); i >= 0 {
+		t.open = slices.Delete(t.open, i, i+1)
+	}
+}
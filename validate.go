@@ -0,0 +1,77 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"entgo.io/ent/dialect"
+	"github.com/goexts/generic/settings"
+)
+
+// ErrInvalidOption is wrapped by the errors NewWithError returns for invalid settings.
+var ErrInvalidOption = errors.New("entslog: invalid option")
+
+// NewWithError is like New, but reports a nil driver and invalid settings, such as a nil
+// filter, level or trace function, or a negative threshold, instead of panicking or
+// misbehaving at query time. The returned error joins every problem found, each wrapping
+// ErrInvalidOption.
+func NewWithError(dri dialect.Driver, ss ...Setting) (dialect.Driver, error) {
+	if dri == nil {
+		return nil, fmt.Errorf("%w: nil driver", ErrInvalidOption)
+	}
+	opt := defaultOption
+	settings.Apply(&opt, ss)
+	if err := opt.validate(); err != nil {
+		return nil, err
+	}
+	return newDriver(dri, &opt), nil
+}
+
+// validate returns the problems of the options, nil when they are valid.
+func (o *Option) validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrInvalidOption}, args...)...))
+	}
+	if o.filter == nil {
+		invalid("nil filter")
+	}
+	if o.level == nil {
+		invalid("nil default level")
+	}
+	if o.errorLevel == nil {
+		invalid("nil error level")
+	}
+	if o.trace == nil {
+		invalid("nil trace function")
+	}
+	if o.slowThreshold < 0 {
+		invalid("negative slow threshold %v", o.slowThreshold)
+	}
+	if o.shutdownTimeout < 0 {
+		invalid("negative shutdown timeout %v", o.shutdownTimeout)
+	}
+	if slices.Contains(o.observers, nil) {
+		invalid("nil observer")
+	}
+	for _, op := range slices.Sorted(maps.Keys(o.opLevels)) {
+		if o.opLevels[op] == nil {
+			invalid("nil level for operation %s", op)
+		}
+	}
+	if slices.Contains(o.sanitizers, nil) {
+		invalid("nil sanitizer")
+	}
+	if slices.ContainsFunc(o.benign, func(fn BenignFunc) bool { return fn == nil }) {
+		invalid("nil benign error function")
+	}
+	if o.statsStore != nil && o.statsInterval < 0 {
+		invalid("negative stats interval %v", o.statsInterval)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2024 OrigAdmin. All rights reserved.
+
+// Package entslog for entgo.io/ent
+package entslog
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// volumeWarmup is the number of windows observed before a fingerprint's baseline is trusted.
+	volumeWarmup = 3
+	// volumeMinCount is the number of executions in a window below which no spike is reported.
+	volumeMinCount = 10
+	// volumeSmoothing is the weight of the last window in the baseline of a fingerprint.
+	volumeSmoothing = 0.3
+)
+
+// volumeWindow counts the executions of one fingerprint in the current window.
+type volumeWindow struct {
+	start    time.Time // beginning of the current window.
+	count    int       // executions in the current window.
+	baseline float64   // moving average of the executions per window.
+	windows  int       // windows folded into the baseline.
+	warned   bool      // whether the current window has already been reported.
+}
+
+// roll folds the windows elapsed at now into the baseline, idle windows counting as zero.
+func (w *volumeWindow) roll(now time.Time, window time.Duration) {
+	n := int(now.Sub(w.start) / window)
+	if n <= 0 {
+		return
+	}
+	count := float64(w.count)
+	for i := 0; i < min(n, 10); i++ {
+		if w.windows == 0 {
+			w.baseline = count
+		} else {
+			w.baseline += volumeSmoothing * (count - w.baseline)
+		}
+		w.windows++
+		count = 0
+	}
+	w.start = w.start.Add(time.Duration(n) * window)
+	w.count, w.warned = 0, false
+}
+
+// volumeTracker detects the fingerprints whose execution rate spikes over their baseline.
+type volumeTracker struct {
+	factor float64       // ratio between the rate and the baseline above which a warning is logged.
+	window time.Duration // period over which executions are counted.
+
+	mu   sync.Mutex
+	byFP map[string]*volumeWindow
+}
+
+func newVolumeTracker(factor float64, window time.Duration) *volumeTracker {
+	return &volumeTracker{factor: factor, window: window, byFP: make(map[string]*volumeWindow)}
+}
+
+// observe counts an execution of fp and returns the window when it spikes for the first
+// time in the current window.
+func (t *volumeTracker) observe(now time.Time, fp string) (volumeWindow, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.byFP[fp]
+	if !ok {
+		if len(t.byFP) >= maxTrackedQueries {
+			return volumeWindow{}, false
+		}
+		w = &volumeWindow{start: now}
+		t.byFP[fp] = w
+	}
+	w.roll(now, t.window)
+	w.count++
+	if w.warned || w.windows < volumeWarmup || w.count < volumeMinCount || float64(w.count) <= t.factor*max(w.baseline, 1) {
+		return volumeWindow{}, false
+	}
+	w.warned = true
+	return *w, true
+}
+
+// observeVolume feeds a completed statement to the volume tracker and warns on spikes.
+func (op *operation) observeVolume() {
+	if op.volume == nil || op.query == "" {
+		return
+	}
+	fp := op.fingerprint()
+	w, ok := op.volume.observe(op.start.Add(op.took), fp)
+	if !ok {
+		return
+	}
+	seconds := op.volume.window.Seconds()
+	op.logAt(op.ctx, slog.LevelWarn, "query volume spike", append(op.subjectAttrs(),
+		slog.Float64("qps", float64(w.count)/seconds),
+		slog.Float64("baseline_qps", w.baseline/seconds),
+		slog.Float64("factor", op.volume.factor),
+		slog.Duration("window", op.volume.window),
+	)...)
+}